@@ -0,0 +1,90 @@
+// Package clock lets the pipeline tell time from the wall clock when live and
+// from exchange event time when replaying, so runs over recorded data are
+// deterministic.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+	// After sends the clock's time once d has passed on it.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the wall clock.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Advancer is a clock that follows event time. The stage consuming events
+// advances it, so every reading downstream of that stage is reproducible.
+type Advancer interface {
+	Advance(t time.Time)
+}
+
+// Simulated is a clock set from event times. It never moves backwards, so
+// late or out-of-order events do not rewind it.
+type Simulated struct {
+	mu     sync.RWMutex
+	now    time.Time
+	timers []timer
+}
+
+type timer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewSimulated creates a clock reading start until it is advanced.
+func NewSimulated(start time.Time) *Simulated {
+	return &Simulated{now: start}
+}
+
+func (s *Simulated) Now() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.now
+}
+
+// After fires once the clock has been advanced d past its current time.
+func (s *Simulated) After(d time.Duration) <-chan time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- s.now
+		return c
+	}
+	s.timers = append(s.timers, timer{at: s.now.Add(d), c: c})
+	return c
+}
+
+// Advance moves the clock to t if t is later than the current time, firing
+// the timers it passes.
+func (s *Simulated) Advance(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !t.After(s.now) {
+		return
+	}
+	s.now = t
+	pending := s.timers[:0]
+	for _, tm := range s.timers {
+		if tm.at.After(t) {
+			pending = append(pending, tm)
+			continue
+		}
+		tm.c <- t
+	}
+	s.timers = pending
+}
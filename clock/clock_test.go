@@ -0,0 +1,41 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulatedAfter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewSimulated(start)
+	fired := c.After(time.Minute)
+
+	c.Advance(start.Add(30 * time.Second))
+	select {
+	case <-fired:
+		t.Fatal("fired before the deadline")
+	default:
+	}
+
+	// Going backwards neither rewinds the clock nor fires the timer
+	c.Advance(start)
+	if got := c.Now(); !got.Equal(start.Add(30 * time.Second)) {
+		t.Fatalf("clock rewound to %v", got)
+	}
+
+	c.Advance(start.Add(2 * time.Minute))
+	select {
+	case at := <-fired:
+		if !at.Equal(start.Add(2 * time.Minute)) {
+			t.Fatalf("fired at %v", at)
+		}
+	default:
+		t.Fatal("not fired after the deadline")
+	}
+
+	select {
+	case <-c.After(0):
+	default:
+		t.Fatal("After(0) did not fire immediately")
+	}
+}
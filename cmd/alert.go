@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/indicator"
+	"fibo-monitor/notification"
+	pkgSignal "fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+// sendTestAlert implements the send-test-alert subcommand: it renders a
+// sample signal with the configured card template and delivers it to every
+// webhook channel accepting its severity, reporting failures.
+func sendTestAlert(args []string) int {
+	fs := flag.NewFlagSet("send-test-alert", flag.ExitOnError)
+	path := fs.String("config", configPath, "path to the config file")
+	severityName := fs.String("severity", "info", "severity of the sample signal: info, warning or critical")
+	symbol := fs.String("symbol", "", "symbol of the sample signal, defaults to the first configured symbol")
+	interval := fs.String("interval", "", "interval of the sample signal, defaults to the first configured interval")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	severity, err := pkgSignal.ParseSeverity(*severityName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *symbol == "" {
+		*symbol = cfg.Symbols[0]
+	}
+	if *interval == "" {
+		*interval = cfg.Intervals[0]
+	}
+
+	logs, err := newLogging(cfg.Monitoring, "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logs.Sync()
+	logger := logs.Logger("notification")
+
+	cfg.Webhook.Enabled = true
+	sender := notification.NewWebhookSender(cfg.Webhook, cfg.MessageCard, clock.Real{}, logger)
+	card := notification.NewMessageCard(cfg.MessageCard).BuildCard(sampleSignal(*symbol, *interval, severity), notification.NoteElement{
+		Tag:      "note",
+		Elements: []notification.TagText{{Tag: "lark_md", Content: "🧪 这是一条测试消息，用于验证 Webhook 配置，数据为示例"}},
+	})
+
+	if err := sender.DeliverCard(card, severity); err != nil {
+		logger.Error("Test alert failed", zap.Error(err))
+		return 1
+	}
+	fmt.Println("test alert delivered")
+	return 0
+}
+
+// sampleSignal is a plausible golden cross with every optional field filled.
+func sampleSignal(symbol, interval string, severity pkgSignal.Severity) pkgSignal.Signal {
+	now := time.Now()
+	return pkgSignal.Signal{
+		Type:         indicator.GoldenCross,
+		Symbol:       strings.ToUpper(symbol),
+		Interval:     interval,
+		Price:        100,
+		ShortEMA:     99.8,
+		LongEMA:      99.5,
+		PrevShortEMA: 99.4,
+		PrevLongEMA:  99.5,
+		Volume:       1000,
+		Timestamp:    now,
+		EventTime:    now,
+		CandleStart:  now.Truncate(time.Minute),
+		CandleClose:  now.Truncate(time.Minute).Add(time.Minute - time.Millisecond),
+		Risk: &pkgSignal.RiskPlan{
+			Method:      "atr",
+			ATR:         1.2,
+			StopLoss:    98.2,
+			TakeProfits: []float64{102.4, 103.6},
+			RiskReward:  1.33,
+		},
+		Timeframes: []pkgSignal.TimeframeTrend{{Interval: interval, Trend: pkgSignal.TrendUp}},
+		Confluence: true,
+		Score:      map[pkgSignal.Severity]float64{pkgSignal.SeverityInfo: 40, pkgSignal.SeverityWarning: 60, pkgSignal.SeverityCritical: 85}[severity],
+		Severity:   severity,
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+	pkgSignal "fibo-monitor/signal"
+)
+
+// klinesPerRequest is the page size of the Binance futures klines endpoint.
+const klinesPerRequest = 1500
+
+// backtest implements the backtest subcommand: it runs closed candles of one
+// pair through the pipeline and reports each signal's outcome.
+func backtest(args []string) int {
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	path := fs.String("config", configPath, "path to the config file")
+	symbol := fs.String("symbol", "", "symbol, defaults to the first configured symbol")
+	interval := fs.String("interval", "", "native interval, defaults to the first configured interval")
+	from := fs.String("from", "", "start date (2006-01-02 or RFC 3339), defaults to 30 days ago, or the start of -input")
+	to := fs.String("to", "", "end date (2006-01-02 or RFC 3339), defaults to now, or the end of -input")
+	input := fs.String("input", "", "CSV of klines in Binance's layout instead of downloading them")
+	restURL := fs.String("rest-url", "https://fapi.binance.com", "Binance futures REST base URL")
+	horizon := fs.Int("horizon", 10, "candles after a signal to measure its return over")
+	logLevel := fs.String("log-level", "warn", "log level")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *symbol == "" {
+		*symbol = cfg.Symbols[0]
+	}
+	if *interval == "" {
+		*interval = cfg.Intervals[0]
+	}
+	// Zero bounds keep the whole input file
+	var start, end time.Time
+	if *from != "" {
+		if start, err = parseTime(*from); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if *to != "" {
+		if end, err = parseTime(*to); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	var klines []kline.Kline
+	if *input != "" {
+		klines, err = readKlinesCSV(*input, *symbol, *interval)
+	} else {
+		if !kline.IsNative(*interval) {
+			fmt.Fprintf(os.Stderr, "backtest: %q is not a native interval\n", *interval)
+			return 2
+		}
+		if end.IsZero() {
+			end = time.Now()
+		}
+		if start.IsZero() {
+			start = end.AddDate(0, 0, -30)
+		}
+		klines, err = fetchKlines(*restURL, *symbol, *interval, start, end)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var candles []kline.Candle
+	var events []kline.KlineEvent
+	invalid := 0
+	for _, k := range klines {
+		c, err := k.ToCandle()
+		if err == nil {
+			err = c.Validate()
+		}
+		if err != nil {
+			invalid++
+			continue
+		}
+		if !start.IsZero() && c.StartTime.Before(start) || !end.IsZero() && c.StartTime.After(end) {
+			continue
+		}
+		candles = append(candles, c)
+		events = append(events, kline.KlineEvent{Event: "kline", Time: k.CloseTime, Symbol: k.Symbol, Kline: k, Candle: c})
+	}
+	if invalid > 0 {
+		fmt.Fprintf(os.Stderr, "backtest: skipped %d invalid klines\n", invalid)
+	}
+	if len(candles) == 0 {
+		fmt.Fprintln(os.Stderr, "backtest: no klines in range")
+		return 1
+	}
+
+	logs, err := newLogging(cfg.Monitoring, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logs.Sync()
+
+	pipe, err := newPipeline(cfg, strategies{current: func() *config.Config { return cfg }}, clock.NewSimulated(time.Time{}), logs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	klineChan := make(chan kline.KlineEvent, 100)
+	go func() {
+		defer close(klineChan)
+		for _, e := range events {
+			klineChan <- e
+		}
+	}()
+
+	index := make(map[int64]int, len(candles))
+	for i, c := range candles {
+		index[c.StartTime.UnixMilli()] = i
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSIGNAL\tPRICE\tSCORE\tSEVERITY\tRETURN\tEXIT")
+	var total, wins, targets, stops int
+	var sumReturn float64
+	for sig := range pipe.signals(klineChan) {
+		i, ok := index[sig.CandleStart.UnixMilli()]
+		if !ok {
+			continue
+		}
+		ret, measured := forwardReturn(sig, candles, i, *horizon)
+		exit := riskExit(sig, candles[i+1:])
+		retText := "-"
+		if measured {
+			retText = fmt.Sprintf("%+.2f%%", ret)
+			total++
+			sumReturn += ret
+			if ret > 0 {
+				wins++
+			}
+		}
+		switch exit {
+		case "target":
+			targets++
+		case "stop":
+			stops++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.8g\t%.0f\t%s\t%s\t%s\n",
+			sig.CandleStart.UTC().Format("2006-01-02 15:04"), sig.String(), sig.Price, sig.Score, sig.Severity, retText, exit)
+	}
+	tw.Flush()
+
+	fmt.Printf("\n%s %s, %d candles from %s to %s\n", strings.ToUpper(*symbol), *interval, len(candles),
+		candles[0].StartTime.Format("2006-01-02 15:04"), candles[len(candles)-1].StartTime.Format("2006-01-02 15:04"))
+	if total > 0 {
+		fmt.Printf("%d signals measured over %d candles: average return %+.2f%%, win rate %.0f%%\n",
+			total, *horizon, sumReturn/float64(total), float64(wins)/float64(total)*100)
+	}
+	if cfg.Risk.Enabled {
+		fmt.Printf("risk exits: %d first target, %d stop\n", targets, stops)
+	}
+	printPaper(pipe)
+	return 0
+}
+
+// forwardReturn is the return in the signal's direction, in percent, after
+// horizon candles.
+func forwardReturn(sig pkgSignal.Signal, candles []kline.Candle, i, horizon int) (float64, bool) {
+	if i+horizon >= len(candles) {
+		return 0, false
+	}
+	ret := (candles[i+horizon].Close - sig.Price) / sig.Price * 100
+	if sig.Type == indicator.DeathCross {
+		ret = -ret
+	}
+	return ret, true
+}
+
+// riskExit reports whether the stop or the first target is hit first. A
+// candle touching both counts as a stop.
+func riskExit(sig pkgSignal.Signal, after []kline.Candle) string {
+	if sig.Risk == nil || len(sig.Risk.TakeProfits) == 0 {
+		return "-"
+	}
+	stop, target := sig.Risk.StopLoss, sig.Risk.TakeProfits[0]
+	long := sig.Type == indicator.GoldenCross
+	for _, c := range after {
+		if long && c.Low <= stop || !long && c.High >= stop {
+			return "stop"
+		}
+		if long && c.High >= target || !long && c.Low <= target {
+			return "target"
+		}
+	}
+	return "open"
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// fetchKlines downloads closed klines from the Binance futures REST API.
+func fetchKlines(restURL, symbol, interval string, start, end time.Time) ([]kline.Kline, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var out []kline.Kline
+	for from := start.UnixMilli(); from < end.UnixMilli(); {
+		q := url.Values{}
+		q.Set("symbol", strings.ToUpper(symbol))
+		q.Set("interval", interval)
+		q.Set("startTime", strconv.FormatInt(from, 10))
+		q.Set("endTime", strconv.FormatInt(end.UnixMilli(), 10))
+		q.Set("limit", strconv.Itoa(klinesPerRequest))
+
+		resp, err := client.Get(strings.TrimSuffix(restURL, "/") + "/fapi/v1/klines?" + q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var rows [][]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&rows)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("klines request failed: %s", resp.Status)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		var lastClose int64
+		for _, row := range rows {
+			fields := make([]string, len(row))
+			for i, v := range row {
+				fields[i] = fmt.Sprint(v)
+				if n, ok := v.(float64); ok {
+					fields[i] = strconv.FormatFloat(n, 'f', -1, 64)
+				}
+			}
+			k, err := parseKlineRow(fields, symbol, interval)
+			if err != nil {
+				return nil, err
+			}
+			lastClose = k.CloseTime
+			// Skip the forming candle
+			if k.CloseTime < time.Now().UnixMilli() {
+				out = append(out, k)
+			}
+		}
+		from = lastClose + 1
+		if len(rows) < klinesPerRequest {
+			break
+		}
+	}
+	return out, nil
+}
+
+// readKlinesCSV reads klines in Binance's layout (open time, open, high,
+// low, close, volume, close time, quote volume, trades, taker buy base,
+// taker buy quote). A header row is skipped.
+func readKlinesCSV(path, symbol, interval string) ([]kline.Kline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	var out []kline.Kline
+	for line := 1; ; line++ {
+		fields, err := r.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := strconv.ParseInt(fields[0], 10, 64); err != nil && line == 1 {
+			continue
+		}
+		k, err := parseKlineRow(fields, symbol, interval)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		out = append(out, k)
+	}
+}
+
+func parseKlineRow(fields []string, symbol, interval string) (kline.Kline, error) {
+	if len(fields) < 11 {
+		return kline.Kline{}, fmt.Errorf("expected at least 11 columns, got %d", len(fields))
+	}
+	openTime, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return kline.Kline{}, fmt.Errorf("invalid open time %q", fields[0])
+	}
+	closeTime, err := strconv.ParseInt(fields[6], 10, 64)
+	if err != nil {
+		return kline.Kline{}, fmt.Errorf("invalid close time %q", fields[6])
+	}
+	trades, _ := strconv.ParseInt(fields[8], 10, 64)
+	return kline.Kline{
+		StartTime:     openTime,
+		CloseTime:     closeTime,
+		Symbol:        strings.ToUpper(symbol),
+		Interval:      interval,
+		Open:          fields[1],
+		High:          fields[2],
+		Low:           fields[3],
+		Close:         fields[4],
+		Volume:        fields[5],
+		QuoteVolume:   fields[7],
+		Trades:        trades,
+		TakerBuyBase:  fields[9],
+		TakerBuyQuote: fields[10],
+		IsClosed:      true,
+	}, nil
+}
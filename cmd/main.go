@@ -3,98 +3,55 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-
-	"fibo-monitor/config"
-	"fibo-monitor/data/kline"
-	"fibo-monitor/data/websocket"
-	"fibo-monitor/monitor"
-	"fibo-monitor/notification"
-	pkgSignal "fibo-monitor/signal"
-
-	"go.uber.org/zap"
+	"strings"
+	_ "time/tzdata" // quiet hours time zones on images without zoneinfo
 )
 
-func main() {
-	// 1. Load Config
-	cfg, err := config.LoadConfig("config/config.yaml")
-	if err != nil {
-		panic(fmt.Sprintf("Failed to load config: %v", err))
-	}
-
-	// 2. Init Logger
-	logger, _ := zap.NewProduction()
-	defer logger.Sync()
-
-	logger.Info("Starting Fibo Monitor...")
-
-	// 3. Init Monitor (Healthcheck only)
-	monServer := monitor.NewServer(cfg.Monitoring, logger)
-	monServer.Start()
+const configPath = "config/config.yaml"
 
-	// 4. Init Components
-	// Webhook
-	webhookSender := notification.NewWebhookSender(cfg.Webhook, cfg.MessageCard, logger)
+const usage = `Usage: fibo-monitor <command> [flags]
 
-	// Filter
-	sigFilter := pkgSignal.NewFilter(cfg.Signal.DeduplicationWindow, logger)
+Commands:
+  run               run the live monitor (default)
+  backtest          run the strategy over historical klines
+  replay            feed recorded stream frames through the pipeline
+  validate-config   check a config file and report every problem
+  send-test-alert   render and deliver a sample signal to the webhooks
+  state dump        save the state of a running monitor to a file
+  state restore     load a saved state into a running monitor
+  mock-binance      serve scripted or recorded klines like the Binance stream
 
-	// Detector
-	detector := pkgSignal.NewDetector(
-		cfg.Indicators.EmaShortPeriod,
-		cfg.Indicators.EmaLongPeriod,
-		logger,
-	)
+Run "fibo-monitor <command> -h" for the flags of a command.
+`
 
-	// Processor
-	processor := kline.NewProcessor(logger)
-
-	// WebSocket Client
-	wsClient := websocket.NewClient(
-		cfg.Binance.WebsocketURL,
-		cfg.Binance.ReconnectInterval,
-		cfg.Binance.PingInterval,
-		logger,
-	)
-
-	// 5. Connect Streams
-	// Prepare stream names: <symbol>@kline_<interval>
-	var streams []string
-	for _, s := range cfg.Symbols {
-		for _, i := range cfg.Intervals {
-			streams = append(streams, fmt.Sprintf("%s@kline_%s", s, i))
-		}
+func main() {
+	command, args := "run", os.Args[1:]
+	// Without a command, or with flags only, run the monitor
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	if err := wsClient.Connect(streams); err != nil {
-		logger.Fatal("Failed to connect to WebSocket", zap.Error(err))
+	var code int
+	switch command {
+	case "run":
+		code = runMonitor(args)
+	case "backtest":
+		code = backtest(args)
+	case "replay":
+		code = replay(args)
+	case "validate-config":
+		code = validateConfig(args)
+	case "send-test-alert":
+		code = sendTestAlert(args)
+	case "state":
+		code = state(args)
+	case "mock-binance":
+		code = mockBinance(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		code = 2
 	}
-
-	// 6. Data Pipeline
-	msgChan := wsClient.Messages()
-	klineChan := processor.Process(msgChan)
-	rawSignalChan := detector.Detect(klineChan)
-	filteredSignalChan := sigFilter.Run(rawSignalChan)
-
-	// FilteredSignalChan -> Webhook
-	go func() {
-		for sig := range filteredSignalChan {
-			logger.Info("Signal Detected", 
-				zap.String("symbol", sig.Symbol),
-				zap.String("interval", sig.Interval),
-				zap.String("type", sig.String()),
-				zap.Float64("price", sig.Price),
-			)
-			webhookSender.Send(sig)
-		}
-	}()
-
-	// 7. Wait for shutdown
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
-
-	logger.Info("Shutting down...")
-	wsClient.Close()
-}
\ No newline at end of file
+	os.Exit(code)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"fibo-monitor/data/kline"
+	"fibo-monitor/data/recording"
+	"fibo-monitor/data/websocket/binancetest"
+
+	"go.uber.org/zap"
+)
+
+// mockBinance implements the mock-binance subcommand: a local stream server
+// publishing scripted or recorded klines, optionally with injected faults.
+// Point binance.websocket_url at the printed URL.
+func mockBinance(args []string) int {
+	fs := flag.NewFlagSet("mock-binance", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:9443", "listen address")
+	input := fs.String("input", "", "recording directory or file to publish instead of scripted klines")
+	speed := fs.Float64("speed", 1, "replay speed of -input, 0 for as fast as possible")
+	symbol := fs.String("symbol", "btcusdt", "symbol of scripted klines")
+	interval := fs.String("interval", "1m", "interval of scripted klines")
+	bars := fs.Int("bars", 1000, "number of scripted klines")
+	every := fs.Duration("every", time.Second, "delay between scripted klines")
+	pingInterval := fs.Duration("ping-interval", 3*time.Minute, "server ping interval, 0 disables pings")
+	disconnectEvery := fs.Duration("disconnect-every", 0, "close connections with a close frame this often")
+	dropEvery := fs.Duration("drop-every", 0, "cut connections without a close frame this often")
+	malformedEvery := fs.Duration("malformed-every", 0, "send a malformed frame this often")
+	stallEvery := fs.Duration("stall-every", 0, "stall all frames this often")
+	stallFor := fs.Duration("stall-for", 10*time.Second, "length of each stall")
+	fs.Parse(args)
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	server := binancetest.NewServer(logger)
+	server.PingInterval = *pingInterval
+	if err := server.Start(*addr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer server.Close()
+	fmt.Println("binance.websocket_url:", server.URL())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	malformed := []byte(`{"stream":"` + *symbol + `@kline_` + *interval + `","data":{"e":"kline",`)
+	faults := []struct {
+		every  time.Duration
+		inject func()
+	}{
+		{*disconnectEvery, server.Disconnect},
+		{*dropEvery, server.Drop},
+		{*malformedEvery, func() { server.SendRaw(malformed) }},
+		{*stallEvery, func() { server.Stall(*stallFor) }},
+	}
+	for _, f := range faults {
+		if f.every > 0 {
+			go repeat(ctx, f.every, f.inject)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if server.WaitConnected(ctx) != nil {
+			return
+		}
+		if *input != "" {
+			publishRecording(ctx, server, *input, *speed, logger)
+			return
+		}
+		size, err := kline.ParseInterval(*interval)
+		if err != nil {
+			logger.Error("Invalid interval", zap.Error(err))
+			return
+		}
+		series := binancetest.Series{
+			Symbol:   *symbol,
+			Interval: *interval,
+			Start:    kline.AlignStart(time.Now().Add(-time.Duration(*bars)*size), size),
+			Bars:     binancetest.Closes(binancetest.Wave(*bars, 100, 5, 60), 10),
+		}
+		payloads, err := series.Payloads()
+		if err != nil {
+			logger.Error("Invalid series", zap.Error(err))
+			return
+		}
+		server.Play(ctx, series.Stream(), payloads, *every)
+		logger.Info("Script finished", zap.Int("klines", len(payloads)))
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-stop:
+	case <-done:
+		// Keep serving so clients can be inspected until interrupted
+		<-stop
+	}
+	return 0
+}
+
+func publishRecording(ctx context.Context, server *binancetest.Server, path string, speed float64, logger *zap.Logger) {
+	reader, err := recording.Open(path)
+	if err != nil {
+		logger.Error("Failed to open recording", zap.Error(err))
+		return
+	}
+	defer reader.Close()
+	for frame := range recording.Play(reader, speed, logger) {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := server.PublishFrame(frame); err != nil {
+			logger.Debug("Frame skipped", zap.Error(err))
+		}
+	}
+}
+
+func repeat(ctx context.Context, every time.Duration, fn func()) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+	"fibo-monitor/logging"
+	"fibo-monitor/paper"
+	"fibo-monitor/risk"
+	pkgSignal "fibo-monitor/signal"
+)
+
+// pipeline is the chain from raw stream frames to filtered, planned signals,
+// shared by run, replay and backtest.
+type pipeline struct {
+	cfg        *config.Config
+	clock      clock.Clock
+	processor  *kline.Processor
+	aggregator *kline.Aggregator
+	barBuilder *kline.BarBuilder
+	store      *kline.Store
+	detector   *pkgSignal.Detector
+	confluence *pkgSignal.Confluence
+	scorer     *pkgSignal.Scorer
+	filter     *pkgSignal.Filter
+	mutes      *pkgSignal.MuteList
+	planner    *risk.Planner
+	trader     *paper.Trader // nil unless paper trading is enabled
+}
+
+// newPipeline builds the stages. With a clock.Simulated the detector advances
+// the clock to the event time of each kline, for replays and backtests.
+func newPipeline(cfg *config.Config, pairStrategies pkgSignal.StrategySource, clk clock.Clock, logs *logging.Logging) (*pipeline, error) {
+	klineLogger := logs.Logger("kline")
+	signalLogger := logs.Logger("signal")
+
+	p := &pipeline{
+		cfg:       cfg,
+		clock:     clk,
+		processor: kline.NewProcessor(klineLogger),
+		store:     kline.NewStore(cfg.Data.HistorySize),
+		filter:    pkgSignal.NewFilter(pairStrategies, dedupPolicy(cfg.Signal.Dedup), clk, signalLogger),
+		mutes:     pkgSignal.NewMuteList(clk, signalLogger),
+		detector: pkgSignal.NewDetector(
+			pairStrategies,
+			indicator.Arithmetic(cfg.Indicators.Arithmetic),
+			pkgSignal.Hysteresis(cfg.Signal.Hysteresis),
+			clk,
+			signalLogger,
+		),
+	}
+
+	// Higher-timeframe confluence
+	p.confluence = pkgSignal.NewConfluence(
+		cfg.Confluence.Intervals,
+		cfg.Confluence.FilterAgainstTrend,
+		cfg.Confluence.MinAligned,
+		p.detector,
+		signalLogger,
+	)
+
+	// Signal strength
+	p.scorer = pkgSignal.NewScorer(cfg.Scoring, p.store, signalLogger)
+
+	// Risk levels
+	p.planner = risk.NewPlanner(cfg.Risk, p.store, logs.Logger("risk"))
+
+	// Simulated trading of the planned signals
+	if cfg.Paper.Enabled {
+		p.trader = paper.NewTrader(cfg.Paper, clk, logs.Logger("paper"))
+	}
+
+	// Aggregator for intervals Binance does not publish
+	nativeIntervals, customIntervals := splitIntervals(cfg.Intervals)
+	if len(customIntervals) > 0 {
+		var err error
+		baseMonitored := contains(nativeIntervals, cfg.Data.BaseInterval)
+		p.aggregator, err = kline.NewAggregator(cfg.Data.BaseInterval, customIntervals, baseMonitored, klineLogger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Bars built from aggregated trades
+	if len(cfg.TradeBars) > 0 {
+		var specs []kline.BarSpec
+		for _, b := range cfg.TradeBars {
+			specs = append(specs, kline.BarSpec{Type: kline.BarType(b.Type), Size: b.Size, Period: b.Period})
+		}
+		var err error
+		p.barBuilder, err = kline.NewBarBuilder(specs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// baseInterval is the interval subscribed for the aggregator, empty without one.
+func (p *pipeline) baseInterval() string {
+	if p.aggregator == nil {
+		return ""
+	}
+	return p.cfg.Data.BaseInterval
+}
+
+// streams returns the streams the pipeline needs for the configuration.
+func (p *pipeline) streams(cfg *config.Config) []string {
+	return streamsFor(cfg, p.baseInterval(), p.barBuilder != nil)
+}
+
+// drop forgets everything kept about a pair no longer monitored.
+func (p *pipeline) drop(symbol, interval string) {
+	p.detector.Drop(symbol, interval)
+	p.store.Drop(symbol, interval)
+	p.filter.Drop(symbol, interval)
+}
+
+// dropSymbol also discards the partial candles built locally for a symbol
+// no longer monitored.
+func (p *pipeline) dropSymbol(symbol string) {
+	if p.aggregator != nil {
+		p.aggregator.Drop(symbol)
+	}
+	if p.barBuilder != nil {
+		p.barBuilder.Drop(symbol)
+		for _, interval := range p.barBuilder.Intervals() {
+			p.drop(symbol, interval)
+		}
+	}
+}
+
+// decode turns raw combined-stream frames into klines, including the
+// intervals built locally.
+func (p *pipeline) decode(msgChan <-chan []byte) <-chan kline.KlineEvent {
+	klineChan := p.processor.Process(msgChan)
+	if p.aggregator != nil {
+		klineChan = p.aggregator.Run(klineChan)
+	}
+	if p.barBuilder != nil {
+		klineChan = kline.Merge(klineChan, p.barBuilder.Run(p.processor.Trades()))
+	}
+	return klineChan
+}
+
+// signals runs klines through detection, confluence, scoring, dedup, mutes
+// and risk planning as configured, paper trading the result.
+func (p *pipeline) signals(klineChan <-chan kline.KlineEvent) <-chan pkgSignal.Signal {
+	storedChan := p.store.Run(klineChan)
+	if p.trader != nil {
+		storedChan = p.trader.Track(storedChan)
+	}
+	signalChan := p.detector.Detect(storedChan)
+	if p.cfg.Confluence.Enabled {
+		signalChan = p.confluence.Run(signalChan)
+	}
+	if p.cfg.Scoring.Enabled {
+		signalChan = p.scorer.Run(signalChan)
+	}
+	signalChan = p.filter.Run(signalChan)
+	signalChan = p.mutes.Run(signalChan)
+	if p.cfg.Risk.Enabled {
+		signalChan = p.planner.Run(signalChan)
+	}
+	if p.trader != nil {
+		signalChan = p.trader.Run(signalChan)
+	}
+	return signalChan
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/data/websocket"
+	"fibo-monitor/data/websocket/binancetest"
+	"fibo-monitor/indicator"
+	"fibo-monitor/logging"
+	pkgSignal "fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+// testConfig is the shipped config reduced to plain EMA crosses of one pair.
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg, err := config.LoadConfig("../config/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Symbols = []string{"btcusdt"}
+	cfg.Intervals = []string{"1m"}
+	cfg.TradeBars = nil
+	cfg.Overrides = config.OverridesConfig{}
+	cfg.Signal.DeduplicationWindow = 0
+	cfg.Signal.MinVolume = 0
+	cfg.Signal.Hysteresis = config.HysteresisConfig{}
+	cfg.Signal.Dedup = config.DedupConfig{EvictionInterval: time.Hour}
+	cfg.Confluence.Enabled = false
+	cfg.Scoring.Enabled = false
+	cfg.Risk.Enabled = false
+	cfg.Paper.Enabled = false
+	cfg.Monitoring.LogLevel = "error"
+	return cfg
+}
+
+// expectedCrosses runs the closes through the EMAs directly.
+func expectedCrosses(closes []float64, shortPeriod, longPeriod int) []indicator.CrossType {
+	short, long := indicator.NewEMA(shortPeriod), indicator.NewEMA(longPeriod)
+	var crosses []indicator.CrossType
+	for _, c := range closes {
+		cross := indicator.CheckCrossover(short.Value, long.Value, short.Calculate(c), long.Calculate(c), c)
+		if cross != indicator.None {
+			crosses = append(crosses, cross)
+		}
+		short.UpdateAndCommit(c)
+		long.UpdateAndCommit(c)
+	}
+	return crosses
+}
+
+func TestPipelineDetectsScriptedCrosses(t *testing.T) {
+	cfg := testConfig(t)
+	logs, err := logging.New(cfg.Monitoring)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := binancetest.NewServer(zap.NewNop())
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	pipe, err := newPipeline(cfg, strategies{current: func() *config.Config { return cfg }}, clock.NewSimulated(time.Time{}), logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := websocket.NewClient(server.URL(), time.Second, 0, zap.NewNop())
+	if err := client.Connect(pipe.streams(cfg)); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	signals := pipe.signals(pipe.decode(client.Messages()))
+
+	closes := binancetest.Wave(1200, 100, 5, 200)
+	series := binancetest.Series{
+		Symbol:   "btcusdt",
+		Interval: "1m",
+		Start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Bars:     binancetest.Closes(closes, 10),
+	}
+	payloads, err := series.Payloads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Paced so the send queue never drops the client
+	go server.Play(ctx, series.Stream(), payloads, 200*time.Microsecond)
+
+	want := expectedCrosses(closes, cfg.Indicators.EmaShortPeriod, cfg.Indicators.EmaLongPeriod)
+	// One cross each way per period of the wave
+	if len(want) != 12 {
+		t.Fatalf("script produces %d crosses, want 12", len(want))
+	}
+	var got []pkgSignal.Signal
+	for len(got) < len(want) {
+		select {
+		case sig := <-signals:
+			got = append(got, sig)
+		case <-ctx.Done():
+			t.Fatalf("received %d of %d signals", len(got), len(want))
+		}
+	}
+	for i, sig := range got {
+		if sig.Type != want[i] {
+			t.Errorf("signal %d is %s at %v, want type %d", i, sig.String(), sig.CandleStart, want[i])
+		}
+		if i > 0 && sig.Type == got[i-1].Type {
+			t.Errorf("signal %d repeats %s", i, sig.String())
+		}
+	}
+	select {
+	case sig := <-signals:
+		t.Fatalf("unexpected extra signal %s at %v", sig.String(), sig.CandleStart)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if rejected := pipe.processor.Rejected(); rejected != 0 {
+		t.Fatalf("%d frames rejected", rejected)
+	}
+}
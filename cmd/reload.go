@@ -0,0 +1,171 @@
+package main
+
+import (
+	"strings"
+
+	"fibo-monitor/config"
+	"fibo-monitor/data/kline"
+	"fibo-monitor/data/websocket"
+	"fibo-monitor/logging"
+	"fibo-monitor/notification"
+	pkgSignal "fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+// splitIntervals separates the intervals Binance publishes from the ones
+// built locally by the aggregator.
+func splitIntervals(intervals []string) (native, custom []string) {
+	for _, i := range intervals {
+		if kline.IsNative(i) {
+			native = append(native, i)
+		} else {
+			custom = append(custom, i)
+		}
+	}
+	return native, custom
+}
+
+// streamsFor returns the streams to subscribe for a configuration. base is
+// the aggregator's base interval, empty without an aggregator, and trades
+// adds the aggTrade stream of every symbol.
+func streamsFor(cfg *config.Config, base string, trades bool) []string {
+	native, _ := splitIntervals(cfg.Intervals)
+	if base != "" && !contains(native, base) {
+		native = append(native, base)
+	}
+
+	var streams []string
+	for _, s := range cfg.Symbols {
+		for _, i := range native {
+			streams = append(streams, websocket.KlineStream(s, i))
+		}
+		if trades {
+			streams = append(streams, websocket.AggTradeStream(s))
+		}
+	}
+	return streams
+}
+
+// strategies resolves pair strategies from the config currently in effect.
+type strategies struct {
+	current func() *config.Config
+}
+
+func (s strategies) Strategy(symbol, interval string) pkgSignal.Strategy {
+	return pkgSignal.Strategy(s.current().Strategy(symbol, interval))
+}
+
+// dedupPolicy converts the dedup settings shared by all pairs.
+func dedupPolicy(cfg config.DedupConfig) pkgSignal.DedupPolicy {
+	return pkgSignal.DedupPolicy{
+		PerPair:          cfg.PerPair,
+		MinPriceMovePct:  cfg.MinPriceMovePct,
+		EvictionInterval: cfg.EvictionInterval,
+	}
+}
+
+// reloader applies a reloaded configuration to the running pipeline.
+type reloader struct {
+	wsClient *websocket.Client
+	// pipe's aggregator and bar builder are created at startup; neither can
+	// be added or reconfigured at runtime
+	pipe          *pipeline
+	webhookSender *notification.WebhookSender
+	summarizer    *notification.Summarizer
+	logs          *logging.Logging
+	// logLevel is the --log-level override, kept over monitoring.log_level
+	logLevel string
+	logger   *zap.Logger
+}
+
+func (r *reloader) apply(old, new *config.Config) {
+	changes := config.Diff(old, new)
+
+	_, oldCustom := splitIntervals(old.Intervals)
+	_, newCustom := splitIntervals(new.Intervals)
+	if strings.Join(oldCustom, ",") != strings.Join(newCustom, ",") ||
+		contains(old.Intervals, old.Data.BaseInterval) != contains(new.Intervals, new.Data.BaseInterval) {
+		changes.RestartRequired = append(changes.RestartRequired, "intervals (locally built)")
+	}
+
+	oldStreams := r.pipe.streams(old)
+	newStreams := r.pipe.streams(new)
+	if added := difference(newStreams, oldStreams); len(added) > 0 {
+		r.logger.Info("Subscribing streams", zap.Strings("streams", added))
+		if err := r.wsClient.Subscribe(added); err != nil {
+			r.logger.Error("Failed to subscribe streams", zap.Error(err))
+		}
+	}
+	if removed := difference(oldStreams, newStreams); len(removed) > 0 {
+		r.logger.Info("Unsubscribing streams", zap.Strings("streams", removed))
+		if err := r.wsClient.Unsubscribe(removed); err != nil {
+			r.logger.Error("Failed to unsubscribe streams", zap.Error(err))
+		}
+	}
+
+	// Forget the state of pairs no longer monitored
+	for _, s := range old.Symbols {
+		symbol := strings.ToUpper(s)
+		for _, i := range old.Intervals {
+			if !contains(new.Symbols, s) || !contains(new.Intervals, i) {
+				r.pipe.drop(symbol, i)
+			}
+		}
+		if !contains(new.Symbols, s) {
+			r.pipe.dropSymbol(symbol)
+		}
+	}
+
+	if changes.Webhook || changes.MessageCard {
+		r.webhookSender.Update(new.Webhook, new.MessageCard)
+		r.logger.Info("Applied webhook and message card settings")
+	}
+	if changes.Filter {
+		r.pipe.filter.SetPolicy(dedupPolicy(new.Signal.Dedup))
+		r.logger.Info("Applied signal filter settings")
+	}
+	if changes.Strategy {
+		// Read through strategies on every event; pairs with new EMA
+		// periods start over on their next update
+		r.logger.Info("Applied strategy settings")
+	}
+	if changes.LogLevels {
+		monitoring := new.Monitoring
+		if r.logLevel != "" {
+			monitoring.LogLevel = r.logLevel
+		}
+		if err := r.logs.Apply(monitoring); err != nil {
+			r.logger.Error("Failed to apply log levels", zap.Error(err))
+		} else {
+			r.logger.Info("Applied log levels")
+		}
+	}
+	if r.summarizer != nil {
+		r.summarizer.SetPairs(new.Symbols, new.Intervals)
+	}
+
+	for _, key := range changes.RestartRequired {
+		r.logger.Warn("Config change requires a restart to take effect", zap.String("setting", key))
+	}
+}
+
+// difference returns the entries of a that are not in b.
+func difference(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if !contains(b, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/data/recording"
+	pkgSignal "fibo-monitor/signal"
+)
+
+// replay implements the replay subcommand: it feeds recorded frames, or raw
+// combined-stream frames one per line, through the pipeline and prints the
+// signals.
+func replay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	path := fs.String("config", configPath, "path to the config file")
+	input := fs.String("input", "", "recording directory, or a file of frames (.gz supported)")
+	speed := fs.String("speed", "max", "max, or a multiple of real time such as 1 or 10 (recordings only)")
+	logLevel := fs.String("log-level", "warn", "log level")
+	fs.Parse(args)
+
+	if *input == "" {
+		fmt.Fprintln(os.Stderr, "replay: -input is required")
+		return 2
+	}
+	var rate float64
+	if *speed != "max" {
+		var err error
+		if rate, err = strconv.ParseFloat(*speed, 64); err != nil || rate <= 0 {
+			fmt.Fprintf(os.Stderr, "replay: invalid -speed %q\n", *speed)
+			return 2
+		}
+	}
+	cfg, err := config.LoadConfig(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	logs, err := newLogging(cfg.Monitoring, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logs.Sync()
+
+	pipe, err := newPipeline(cfg, strategies{current: func() *config.Config { return cfg }}, clock.NewSimulated(time.Time{}), logs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	reader, err := recording.Open(*input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer reader.Close()
+
+	count := 0
+	msgChan := recording.Play(reader, rate, logs.Logger("recording"))
+	for sig := range pipe.signals(pipe.decode(msgChan)) {
+		printSignal(sig)
+		count++
+	}
+	fmt.Printf("%d signals, %d frames rejected\n", count, pipe.processor.Rejected())
+	printPaper(pipe)
+	return 0
+}
+
+func printSignal(sig pkgSignal.Signal) {
+	fmt.Printf("%s %s %s %s price=%.8g score=%.0f severity=%s\n",
+		sig.CandleStart.UTC().Format("2006-01-02 15:04:05"),
+		sig.Symbol, sig.Interval, sig.String(), sig.Price, sig.Score, sig.Severity)
+}
+
+// printPaper prints the paper trading result when it is enabled.
+func printPaper(pipe *pipeline) {
+	if pipe.trader == nil {
+		return
+	}
+	s := pipe.trader.Summary(time.Time{})
+	fmt.Printf("paper: equity %.2f (%+.2f%%), realized %+.2f, unrealized %+.2f, fees %.2f, %d trades, %d wins, %d open\n",
+		s.Equity, (s.Equity/s.InitialBalance-1)*100, s.RealizedPnL, s.UnrealizedPnL, s.Fees, s.Trades, s.Wins, len(s.Positions))
+}
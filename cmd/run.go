@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/data/recording"
+	"fibo-monitor/data/websocket"
+	"fibo-monitor/logging"
+	"fibo-monitor/monitor"
+	"fibo-monitor/notification"
+	pkgSignal "fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+// runMonitor implements the run subcommand: the live monitor.
+func runMonitor(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	path := fs.String("config", configPath, "path to the config file")
+	logLevel := fs.String("log-level", "", "log level, overrides monitoring.log_level")
+	dryRun := fs.Bool("dry-run", false, "run the full pipeline but log notifications instead of sending them")
+	stateFile := fs.String("state", "", "restore state from this file at startup and save it there on shutdown")
+	fs.Parse(args)
+
+	// 1. Load Config
+	cfg, err := config.LoadConfig(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+
+	// 2. Init Logger
+	logs, err := newLogging(cfg.Monitoring, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create logger: %v\n", err)
+		return 1
+	}
+	defer logs.Sync()
+	logger := logs.Logger("")
+	notifyLogger := logs.Logger("notification")
+
+	logger.Info("Starting Fibo Monitor...", zap.Bool("dry_run", *dryRun))
+
+	// 3. Init Monitor
+	monServer := monitor.NewServer(cfg.Monitoring, logs.Logger("monitor"))
+
+	// 4. Init Components
+	// Webhook
+	webhookSender := notification.NewWebhookSender(cfg.Webhook, cfg.MessageCard, clock.Real{}, notifyLogger)
+	webhookSender.SetDryRun(*dryRun)
+
+	// Digest batching and periodic summaries
+	var notifier interface{ Send(pkgSignal.Signal) } = webhookSender
+	var batcher *notification.Batcher
+	if cfg.Notification.Digest.Enabled {
+		batcher = notification.NewBatcher(cfg.Notification.Digest.Window, webhookSender, notifyLogger)
+		notifier = batcher
+	}
+
+	// Config reloads; per-pair strategies are always read from the current config
+	reload := &reloader{logs: logs, logLevel: *logLevel, logger: logger}
+	watcher := config.NewWatcher(*path, cfg, reload.apply, logs.Logger("config"))
+
+	pipe, err := newPipeline(cfg, strategies{current: watcher.Current}, clock.Real{}, logs)
+	if err != nil {
+		logger.Error("Invalid pipeline configuration", zap.Error(err))
+		return 1
+	}
+
+	if *stateFile != "" {
+		if err := loadState(*stateFile, pipe); err != nil {
+			logger.Warn("State not restored", zap.String("path", *stateFile), zap.Error(err))
+		} else {
+			logger.Info("State restored", zap.String("path", *stateFile))
+		}
+	}
+
+	// WebSocket Client
+	wsClient := websocket.NewClient(
+		cfg.Binance.WebsocketURL,
+		cfg.Binance.ReconnectInterval,
+		cfg.Binance.PingInterval,
+		logs.Logger("websocket"),
+	)
+
+	var summarizer *notification.Summarizer
+	if cfg.Notification.Summary.Enabled {
+		summarizer = notification.NewSummarizer(cfg.Notification.Summary.Interval, cfg.Symbols, cfg.Intervals, pipe.detector, webhookSender, notifyLogger)
+		summarizer.Start()
+	}
+
+	var paperReporter *notification.PaperReporter
+	if pipe.trader != nil && cfg.Paper.DailySummary {
+		location, _ := time.LoadLocation(cfg.Paper.Timezone)
+		paperReporter = notification.NewPaperReporter(pipe.trader, location, webhookSender, notifyLogger)
+		paperReporter.Start()
+	}
+
+	// Muted pairs, controlled from Lark card buttons
+	if callbackCfg := cfg.MessageCard.LarkSpecific.Callback; callbackCfg.Enabled {
+		monServer.Handle(callbackCfg.Path, notification.NewCallbackHandler(callbackCfg, webhookSender, pipe.mutes, pipe.store, notifyLogger))
+	}
+
+	monServer.HandleJSON("/signals/suppressed", func() interface{} {
+		return pipe.detector.Suppressed()
+	})
+	monServer.HandleJSON("/stats", func() interface{} {
+		return map[string]interface{}{
+			"rejected_messages": pipe.processor.Rejected(),
+			"webhook":           webhookSender.Stats(),
+		}
+	})
+	if pipe.trader != nil {
+		monServer.HandleJSON("/paper", func() interface{} {
+			return pipe.trader.Summary(time.Time{})
+		})
+		monServer.HandleJSON("/paper/equity", func() interface{} {
+			return pipe.trader.Equity()
+		})
+		monServer.HandleJSON("/paper/trades", func() interface{} {
+			return pipe.trader.Trades()
+		})
+	}
+	monServer.HandleAdmin("/state", stateHandler{pipe: pipe, logger: logger})
+	monServer.HandleAdmin("/log/level", logs)
+	monServer.HandleAdmin("/config", monServer.JSONHandler(func() interface{} {
+		return watcher.Current().Redacted()
+	}))
+	monServer.Start()
+
+	// 5. Connect Streams
+	// Stream names: <symbol>@kline_<interval>, <symbol>@aggTrade
+	if err := wsClient.Connect(pipe.streams(cfg)); err != nil {
+		logger.Error("Failed to connect to WebSocket", zap.Error(err))
+		return 1
+	}
+
+	// 6. Data Pipeline
+	// Frames stop at shutdown so the stages below drain and close in turn
+	stopFeed := make(chan struct{})
+	msgChan := until(stopFeed, wsClient.Messages())
+	var recorder *recording.Recorder
+	if cfg.Data.Recording.Enabled {
+		recorder, err = recording.NewRecorder(cfg.Data.Recording, logs.Logger("recording"))
+		if err != nil {
+			logger.Error("Failed to start recorder", zap.Error(err))
+			return 1
+		}
+		msgChan = recorder.Run(msgChan)
+	}
+	filteredSignalChan := pipe.signals(pipe.decode(msgChan))
+
+	// FilteredSignalChan -> Webhook
+	notified := make(chan struct{})
+	go func() {
+		defer close(notified)
+		for sig := range filteredSignalChan {
+			logger.Info("Signal Detected",
+				zap.String("symbol", sig.Symbol),
+				zap.String("interval", sig.Interval),
+				zap.String("type", sig.String()),
+				zap.Float64("price", sig.Price),
+				zap.Float64("score", sig.Score),
+				zap.String("severity", sig.Severity.String()),
+			)
+			if summarizer != nil {
+				summarizer.Record(sig)
+			}
+			notifier.Send(sig)
+		}
+	}()
+
+	// 7. Reload config on file change or SIGHUP
+	reload.wsClient = wsClient
+	reload.pipe = pipe
+	reload.webhookSender = webhookSender
+	reload.summarizer = summarizer
+	if err := watcher.Start(); err != nil {
+		logger.Warn("Config file watching disabled", zap.Error(err))
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("Received SIGHUP, reloading config")
+			watcher.Reload()
+		}
+	}()
+
+	// 8. Wait for shutdown
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	logger.Info("Shutting down...")
+	if summarizer != nil {
+		summarizer.Stop()
+	}
+	if paperReporter != nil {
+		paperReporter.Stop()
+	}
+	wsClient.Close()
+	close(stopFeed)
+
+	// Deliver what the pipeline still holds before exiting
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		logger.Warn("Pipeline did not drain, pending signals may be lost")
+	}
+	if batcher != nil {
+		batcher.Flush()
+	}
+	webhookSender.Stop()
+	if recorder != nil {
+		recorder.Close()
+	}
+
+	if *stateFile != "" {
+		if err := saveState(*stateFile, pipe); err != nil {
+			logger.Error("Failed to save state", zap.String("path", *stateFile), zap.Error(err))
+		} else {
+			logger.Info("State saved", zap.String("path", *stateFile))
+		}
+	}
+	return 0
+}
+
+// until forwards frames until stop is closed, then closes its output.
+func until(stop <-chan struct{}, in <-chan []byte) <-chan []byte {
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg := <-in:
+				select {
+				case out <- msg:
+				case <-stop:
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// newLogging creates the loggers from the monitoring config; a non-empty
+// level overrides monitoring.log_level.
+func newLogging(cfg config.MonitoringConfig, level string) (*logging.Logging, error) {
+	if level != "" {
+		cfg.LogLevel = level
+	}
+	return logging.New(cfg)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"fibo-monitor/data/kline"
+	pkgSignal "fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+// monitorState is what survives a restart: candle history, from which the
+// detector is warmed up again, plus mutes and dedup entries.
+type monitorState struct {
+	SavedAt time.Time              `json:"saved_at"`
+	Candles []kline.Candle         `json:"candles"` // closed candles, oldest first per pair
+	Mutes   []pkgSignal.Mute       `json:"mutes"`
+	Dedup   []pkgSignal.DedupEntry `json:"dedup"`
+}
+
+func captureState(p *pipeline) monitorState {
+	now := p.clock.Now()
+	s := monitorState{
+		SavedAt: now,
+		Mutes:   p.mutes.Mutes(now),
+		Dedup:   p.filter.Entries(),
+	}
+	for _, pair := range p.store.Pairs() {
+		s.Candles = append(s.Candles, p.store.Snapshot(pair.Symbol, pair.Interval)...)
+	}
+	return s
+}
+
+func restoreState(p *pipeline, s monitorState) {
+	for _, c := range s.Candles {
+		p.store.Update(c)
+	}
+	for _, pair := range p.store.Pairs() {
+		p.detector.Warm(pair.Symbol, pair.Interval, p.store.Snapshot(pair.Symbol, pair.Interval))
+	}
+	for _, m := range s.Mutes {
+		p.mutes.Mute(m.Symbol, m.Interval, m.Until)
+	}
+	p.filter.Restore(s.Dedup)
+}
+
+func loadState(path string, p *pipeline) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var s monitorState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	restoreState(p, s)
+	return nil
+}
+
+func saveState(path string, p *pipeline) error {
+	data, err := json.Marshal(captureState(p))
+	if err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a truncated file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// stateHandler serves the state on GET and restores a posted state on POST.
+type stateHandler struct {
+	pipe   *pipeline
+	logger *zap.Logger
+}
+
+func (h stateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(captureState(h.pipe))
+	case http.MethodPost:
+		var s monitorState
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "invalid state: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		restoreState(h.pipe, s)
+		h.logger.Info("State restored over HTTP",
+			zap.Time("saved_at", s.SavedAt),
+			zap.Int("candles", len(s.Candles)),
+			zap.Int("mutes", len(s.Mutes)),
+		)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// state implements "state dump" and "state restore" against a running
+// monitor's /state endpoint.
+func state(args []string) int {
+	if len(args) == 0 || args[0] != "dump" && args[0] != "restore" {
+		fmt.Fprintln(os.Stderr, "usage: fibo-monitor state dump|restore [flags]")
+		return 2
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("state "+action, flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "base URL of the running monitor")
+	file := fs.String("file", "", "state file; dump writes stdout and restore reads stdin when empty")
+	token := fs.String("token", os.Getenv("FIBO_MONITORING_ADMIN_TOKEN"), "monitoring.admin_token of the running monitor")
+	fs.Parse(args[1:])
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	url := *addr + "/state"
+	request := func(method string, body io.Reader) (*http.Response, error) {
+		req, err := http.NewRequest(method, url, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if *token != "" {
+			req.Header.Set("Authorization", "Bearer "+*token)
+		}
+		return client.Do(req)
+	}
+
+	if action == "dump" {
+		resp, err := request(http.MethodGet, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", url, resp.Status)
+			return 1
+		}
+		out := io.Writer(os.Stdout)
+		if *file != "" {
+			f, err := os.Create(*file)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	var data []byte
+	var err error
+	if *file != "" {
+		data, err = os.ReadFile(*file)
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	resp, err := request(http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "%s: %s %s\n", url, resp.Status, bytes.TrimSpace(body))
+		return 1
+	}
+	fmt.Println("state restored")
+	return 0
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"fibo-monitor/config"
+)
+
+// validateConfig implements the validate-config subcommand, for use in CI:
+// it prints every problem found and returns a non-zero exit code if any.
+func validateConfig(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	path := fs.String("config", configPath, "path to the config file")
+	fs.Parse(args)
+
+	if _, err := config.LoadConfig(*path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s: OK\n", *path)
+	return 0
+}
@@ -1,21 +1,34 @@
 package config
 
 import (
+	"errors"
+	"regexp"
 	"strings"
 	"time"
 
+	"fibo-monitor/redact"
+
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Binance     BinanceConfig     `mapstructure:"binance"`
-	Symbols     []string          `mapstructure:"symbols"`
-	Intervals   []string          `mapstructure:"intervals"`
-	Indicators  IndicatorsConfig  `mapstructure:"indicators"`
-	Signal      SignalConfig      `mapstructure:"signal"`
-	Webhook     WebhookConfig     `mapstructure:"webhook"`
-	MessageCard MessageCardConfig `mapstructure:"message_card"`
-	Monitoring  MonitoringConfig  `mapstructure:"monitoring"`
+	Binance      BinanceConfig      `mapstructure:"binance"`
+	Symbols      []string           `mapstructure:"symbols"`
+	Intervals    []string           `mapstructure:"intervals"`
+	Data         DataConfig         `mapstructure:"data"`
+	TradeBars    []TradeBarConfig   `mapstructure:"trade_bars"`
+	Indicators   IndicatorsConfig   `mapstructure:"indicators"`
+	Signal       SignalConfig       `mapstructure:"signal"`
+	Overrides    OverridesConfig    `mapstructure:"overrides"`
+	Risk         RiskConfig         `mapstructure:"risk"`
+	Confluence   ConfluenceConfig   `mapstructure:"confluence"`
+	Scoring      ScoringConfig      `mapstructure:"scoring"`
+	Paper        PaperConfig        `mapstructure:"paper"`
+	Webhook      WebhookConfig      `mapstructure:"webhook"`
+	MessageCard  MessageCardConfig  `mapstructure:"message_card"`
+	Notification NotificationConfig `mapstructure:"notification"`
+	Monitoring   MonitoringConfig   `mapstructure:"monitoring"`
 }
 
 type BinanceConfig struct {
@@ -24,23 +37,188 @@ type BinanceConfig struct {
 	PingInterval      time.Duration `mapstructure:"ping_interval"`
 }
 
+type DataConfig struct {
+	HistorySize int `mapstructure:"history_size"`
+	// BaseInterval is the native interval subscribed to when building
+	// non-native intervals locally.
+	BaseInterval string `mapstructure:"base_interval"`
+	// Recording writes the raw stream to disk for the replay command
+	Recording RecordingConfig `mapstructure:"recording"`
+}
+
+// RecordingConfig rotates recordings every RotateInterval or after MaxSizeMB
+// of uncompressed frames, keeping the newest MaxFiles (0 keeps all).
+type RecordingConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Dir            string        `mapstructure:"dir"`
+	RotateInterval time.Duration `mapstructure:"rotate_interval"`
+	MaxSizeMB      int           `mapstructure:"max_size_mb"`
+	MaxFiles       int           `mapstructure:"max_files"`
+}
+
+// TradeBarConfig describes bars built locally from the aggTrade stream.
+type TradeBarConfig struct {
+	Type   string        `mapstructure:"type"`   // time, tick, volume or dollar
+	Size   float64       `mapstructure:"size"`   // trades, base volume or quote volume per bar
+	Period time.Duration `mapstructure:"period"` // bar length for time bars
+}
+
 type IndicatorsConfig struct {
-	EmaShortPeriod int `mapstructure:"ema_short_period"`
-	EmaLongPeriod  int `mapstructure:"ema_long_period"`
+	EmaShortPeriod int    `mapstructure:"ema_short_period"`
+	EmaLongPeriod  int    `mapstructure:"ema_long_period"`
+	Arithmetic     string `mapstructure:"arithmetic"` // float or decimal
 }
 
 type SignalConfig struct {
-	DeduplicationWindow time.Duration `mapstructure:"deduplication_window"`
-	MinVolume           float64       `mapstructure:"min_volume"`
+	DeduplicationWindow time.Duration    `mapstructure:"deduplication_window"`
+	MinVolume           float64          `mapstructure:"min_volume"`
+	Hysteresis          HysteresisConfig `mapstructure:"hysteresis"`
+	Dedup               DedupConfig      `mapstructure:"dedup"`
+}
+
+type DedupConfig struct {
+	PerPair          bool          `mapstructure:"per_pair"`
+	WindowCandles    int           `mapstructure:"window_candles"`
+	MinPriceMovePct  float64       `mapstructure:"min_price_move_pct"`
+	EvictionInterval time.Duration `mapstructure:"eviction_interval"`
+}
+
+type HysteresisConfig struct {
+	MinSpreadPct float64 `mapstructure:"min_spread_pct"`
+	MinSpreadATR float64 `mapstructure:"min_spread_atr"`
+	ATRPeriod    int     `mapstructure:"atr_period"`
+	ConfirmTicks int     `mapstructure:"confirm_ticks"`
+	ConfirmBars  int     `mapstructure:"confirm_bars"`
+	RecordSize   int     `mapstructure:"record_size"`
+}
+
+type ConfluenceConfig struct {
+	Enabled            bool     `mapstructure:"enabled"`
+	FilterAgainstTrend bool     `mapstructure:"filter_against_trend"`
+	MinAligned         int      `mapstructure:"min_aligned"`
+	Intervals          []string `mapstructure:"intervals"` // higher intervals to consult, defaults to all monitored intervals
+}
+
+type ScoringConfig struct {
+	Enabled        bool         `mapstructure:"enabled"`
+	MinScore       float64      `mapstructure:"min_score"`
+	WarningScore   float64      `mapstructure:"warning_score"`
+	CriticalScore  float64      `mapstructure:"critical_score"`
+	VolumeLookback int          `mapstructure:"volume_lookback"`
+	RSIPeriod      int          `mapstructure:"rsi_period"`
+	Weights        ScoreWeights `mapstructure:"weights"`
+}
+
+type ScoreWeights struct {
+	Slope     float64 `mapstructure:"slope"`
+	Volume    float64 `mapstructure:"volume"`
+	Distance  float64 `mapstructure:"distance"`
+	Alignment float64 `mapstructure:"alignment"`
+	RSI       float64 `mapstructure:"rsi"`
+}
+
+type RiskConfig struct {
+	Enabled   bool                `mapstructure:"enabled"`
+	RiskRule  `mapstructure:",squash"`
+	Intervals map[string]RiskRule `mapstructure:"intervals"` // per-interval overrides
+}
+
+type RiskRule struct {
+	Method        string    `mapstructure:"method"` // atr or fibonacci
+	ATRPeriod     int       `mapstructure:"atr_period"`
+	StopLossATR   float64   `mapstructure:"stop_loss_atr"`
+	TakeProfitATR []float64 `mapstructure:"take_profit_atr"`
+	FibLookback   int       `mapstructure:"fib_lookback"` // closed candles searched for the swing high/low
+}
+
+// ForInterval returns the rule for an interval, with per-interval fields
+// overriding the global ones where set.
+func (r RiskConfig) ForInterval(interval string) RiskRule {
+	rule := r.RiskRule
+	o, ok := r.Intervals[interval]
+	if !ok {
+		return rule
+	}
+	if o.Method != "" {
+		rule.Method = o.Method
+	}
+	if o.ATRPeriod != 0 {
+		rule.ATRPeriod = o.ATRPeriod
+	}
+	if o.StopLossATR != 0 {
+		rule.StopLossATR = o.StopLossATR
+	}
+	if len(o.TakeProfitATR) > 0 {
+		rule.TakeProfitATR = o.TakeProfitATR
+	}
+	if o.FibLookback != 0 {
+		rule.FibLookback = o.FibLookback
+	}
+	return rule
+}
+
+// PaperConfig simulates trading the filtered signals, one position per
+// symbol, exiting at the risk plan's stop and targets.
+type PaperConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	InitialBalance float64 `mapstructure:"initial_balance"`
+	// Sizing is notional (Size in quote currency), equity_pct (Size percent
+	// of equity) or risk_pct (lose Size percent of equity at the stop)
+	Sizing         string   `mapstructure:"sizing"`
+	Size           float64  `mapstructure:"size"`
+	MaxLeverage    float64  `mapstructure:"max_leverage"` // caps notional at equity × this, 0 for no cap
+	FeePct         float64  `mapstructure:"fee_pct"`      // per fill, of notional
+	SlippagePct    float64  `mapstructure:"slippage_pct"` // against market fills; targets fill at their price
+	Intervals      []string `mapstructure:"intervals"`    // signal intervals traded, empty for all
+	ExitOnOpposite bool     `mapstructure:"exit_on_opposite"`
+	// EquityInterval is how often the equity curve is sampled; EquityPoints
+	// bounds its length
+	EquityInterval time.Duration `mapstructure:"equity_interval"`
+	EquityPoints   int           `mapstructure:"equity_points"`
+	// DailySummary sends a summary card at midnight in Timezone
+	DailySummary bool   `mapstructure:"daily_summary"`
+	Timezone     string `mapstructure:"timezone"`
 }
 
 type WebhookConfig struct {
-	Enabled      bool          `mapstructure:"enabled"`
-	URL          string        `mapstructure:"url"`
-	Secret       string        `mapstructure:"secret"`
-	Timeout      time.Duration `mapstructure:"timeout"`
-	RetryCount   int           `mapstructure:"retry_count"`
-	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	Enabled      bool             `mapstructure:"enabled"`
+	URL          string           `mapstructure:"url" secret:"true"`
+	Secret       string           `mapstructure:"secret" secret:"true"`
+	MinSeverity  string           `mapstructure:"min_severity"`
+	RateLimit    RateLimitConfig  `mapstructure:"rate_limit"`
+	QuietHours   QuietHoursConfig `mapstructure:"quiet_hours"`
+	Timeout      time.Duration    `mapstructure:"timeout"`
+	RetryCount   int              `mapstructure:"retry_count"`
+	RetryBackoff time.Duration    `mapstructure:"retry_backoff"`
+	// Channels are additional webhooks, e.g. an on-call group that only
+	// receives critical signals
+	Channels []ChannelConfig `mapstructure:"channels"`
+}
+
+type ChannelConfig struct {
+	Name        string           `mapstructure:"name"`
+	URL         string           `mapstructure:"url" secret:"true"`
+	Secret      string           `mapstructure:"secret" secret:"true"`
+	MinSeverity string           `mapstructure:"min_severity"` // info, warning or critical
+	RateLimit   RateLimitConfig  `mapstructure:"rate_limit"`
+	QuietHours  QuietHoursConfig `mapstructure:"quiet_hours"`
+}
+
+// RateLimitConfig is a token bucket; PerMinute of 0 disables limiting.
+type RateLimitConfig struct {
+	PerMinute float64 `mapstructure:"per_minute"`
+	Burst     int     `mapstructure:"burst"`
+}
+
+// QuietHoursConfig is a daily window (which may span midnight) during which
+// only signals of at least MinSeverity are delivered; the rest are queued
+// until it ends.
+type QuietHoursConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Timezone    string `mapstructure:"timezone"` // IANA name, e.g. Asia/Shanghai
+	Start       string `mapstructure:"start"`    // HH:MM
+	End         string `mapstructure:"end"`      // HH:MM
+	MinSeverity string `mapstructure:"min_severity"`
 }
 
 type MessageCardConfig struct {
@@ -53,41 +231,143 @@ type MessageCardConfig struct {
 }
 
 type LarkSpecificConfig struct {
-	AtAll   bool           `mapstructure:"at_all"`
-	AtUsers []string       `mapstructure:"at_users"`
-	Buttons []ButtonConfig `mapstructure:"buttons"`
+	AtAll    bool               `mapstructure:"at_all"`
+	AtUsers  []string           `mapstructure:"at_users"`
+	Buttons  []ButtonConfig     `mapstructure:"buttons"`
+	Callback LarkCallbackConfig `mapstructure:"callback"`
+	Mentions []MentionRule      `mapstructure:"mentions"`
+}
+
+// MentionRule mentions extra people for signals matching all of its conditions.
+// Empty intervals or symbols match any, an empty min_severity means info.
+type MentionRule struct {
+	MinSeverity string   `mapstructure:"min_severity"`
+	Intervals   []string `mapstructure:"intervals"`
+	Symbols     []string `mapstructure:"symbols"`
+	Users       []string `mapstructure:"users"`
+	AtAll       bool     `mapstructure:"at_all"`
 }
 
 type ButtonConfig struct {
-	Text   string `mapstructure:"text"`
-	URL    string `mapstructure:"url"`
-	Action string `mapstructure:"action"`
+	Text      string  `mapstructure:"text"`
+	URL       string  `mapstructure:"url"`
+	Action    string  `mapstructure:"action"`     // empty for a link button, or ack, ignore, mute, chart
+	MuteHours float64 `mapstructure:"mute_hours"` // for mute buttons
+}
+
+// LarkCallbackConfig configures the endpoint receiving card button clicks.
+type LarkCallbackConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	Path              string `mapstructure:"path"`
+	VerificationToken string `mapstructure:"verification_token" secret:"true"`
+	EncryptKey        string `mapstructure:"encrypt_key" secret:"true"`
+}
+
+type NotificationConfig struct {
+	Digest  DigestConfig  `mapstructure:"digest"`
+	Summary SummaryConfig `mapstructure:"summary"`
+}
+
+type DigestConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Window  time.Duration `mapstructure:"window"`
+}
+
+type SummaryConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
 }
 
 type MonitoringConfig struct {
 	HealthcheckPort   int    `mapstructure:"healthcheck_port"`
 	LogLevel          string `mapstructure:"log_level"`
+	Logging           LoggingConfig `mapstructure:"logging"`
+	// AdminToken guards the endpoints changing or exposing internal state.
+	// Without it they only answer requests from localhost.
+	AdminToken string `mapstructure:"admin_token" secret:"true"`
+}
+
+type LoggingConfig struct {
+	Encoding         string         `mapstructure:"encoding"` // json or console
+	OutputPaths      []string       `mapstructure:"output_paths"`
+	ErrorOutputPaths []string       `mapstructure:"error_output_paths"`
+	Sampling         SamplingConfig `mapstructure:"sampling"`
+	// Components sets the level of single components, e.g. websocket: debug.
+	// Components not listed follow log_level.
+	Components map[string]string `mapstructure:"components"`
 }
 
+// SamplingConfig keeps the first Initial entries with the same message each
+// second, then every Thereafter-th.
+type SamplingConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	Initial    int  `mapstructure:"initial"`
+	Thereafter int  `mapstructure:"thereafter"`
+}
+
+// LoadConfig reads, defaults and validates the configuration file. Unknown
+// keys and invalid values are reported together, each with its YAML path.
 func LoadConfig(path string) (*Config, error) {
-	viper.SetConfigFile(path)
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("FIBO")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.AutomaticEnv()
+	v.SetEnvPrefix("FIBO")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	setDefaults(v)
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		return nil, err
 	}
 
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, err
 	}
+	applyDerived(&config)
 
-	// Set default values if not present
-	if config.Webhook.Timeout == 0 {
-		config.Webhook.Timeout = 10 * time.Second
+	// Unresolved secrets would only fail validation again
+	errs := unknownKeys(v)
+	if secretErrs := resolveSecrets(&config); len(secretErrs) > 0 {
+		errs = append(errs, secretErrs...)
+	} else {
+		redact.Add(config.Secrets()...)
+		var validationErrs ValidationErrors
+		if err := config.Validate(); errors.As(err, &validationErrs) {
+			errs = append(errs, validationErrs...)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
-
 	return &config, nil
-}
\ No newline at end of file
+}
+
+var invalidKeysPattern = regexp.MustCompile(`^'(.*)' has invalid keys: (.*)$`)
+
+// unknownKeys reports settings that do not exist, usually typos.
+func unknownKeys(v *viper.Viper) ValidationErrors {
+	var probe Config
+	err := v.UnmarshalExact(&probe)
+	var decodeErr *mapstructure.Error
+	if !errors.As(err, &decodeErr) {
+		return nil
+	}
+
+	var errs ValidationErrors
+	for _, msg := range decodeErr.Errors {
+		m := invalidKeysPattern.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		for _, key := range strings.Split(m[2], ", ") {
+			path := key
+			if m[1] != "" {
+				path = m[1] + "." + key
+			}
+			errs = append(errs, ValidationError{Path: path, Message: "unknown setting"})
+		}
+	}
+	return errs
+}
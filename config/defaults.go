@@ -0,0 +1,107 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaults is the value of every setting left out of config.yaml. Settings
+// missing here default to the zero value, which disables the feature.
+var defaults = map[string]interface{}{
+	// Binance futures combined streams
+	"binance.websocket_url":      "wss://fstream.binance.com/ws",
+	"binance.reconnect_interval": 5 * time.Second,
+	"binance.ping_interval":      30 * time.Second,
+
+	// Closed candles kept per pair, and the native interval non-native
+	// intervals are aggregated from
+	"data.history_size":  500,
+	"data.base_interval": "1m",
+
+	"data.recording.dir":             "recordings",
+	"data.recording.rotate_interval": time.Hour,
+	"data.recording.max_size_mb":     100,
+	"data.recording.max_files":       168,
+
+	"indicators.ema_short_period": 12,
+	"indicators.ema_long_period":  144,
+	"indicators.arithmetic":       "float",
+
+	"signal.deduplication_window":    10 * time.Minute,
+	"signal.dedup.eviction_interval": time.Hour,
+	"signal.hysteresis.atr_period":   14,
+	"signal.hysteresis.record_size":  200,
+
+	// Confluence intervals default to the monitored intervals, see applyDerived
+	"confluence.min_aligned": 2,
+
+	"scoring.warning_score":     50,
+	"scoring.critical_score":    75,
+	"scoring.volume_lookback":   20,
+	"scoring.rsi_period":        14,
+	"scoring.weights.slope":     1,
+	"scoring.weights.volume":    1,
+	"scoring.weights.distance":  1,
+	"scoring.weights.alignment": 1,
+	"scoring.weights.rsi":       1,
+
+	"risk.method":          "atr",
+	"risk.atr_period":      14,
+	"risk.stop_loss_atr":   1.5,
+	"risk.take_profit_atr": []float64{2, 3},
+	"risk.fib_lookback":    100,
+
+	"paper.initial_balance":  10000,
+	"paper.sizing":           "equity_pct",
+	"paper.size":             10,
+	"paper.max_leverage":     1,
+	"paper.fee_pct":          0.04,
+	"paper.slippage_pct":     0.02,
+	"paper.exit_on_opposite": true,
+	"paper.equity_interval":  time.Minute,
+	"paper.equity_points":    1440,
+	"paper.timezone":         "UTC",
+
+	"webhook.min_severity":  "info",
+	"webhook.timeout":       10 * time.Second,
+	"webhook.retry_count":   3,
+	"webhook.retry_backoff": time.Second,
+
+	"message_card.lark_specific.callback.path": "/lark/callback",
+
+	"notification.digest.window":    30 * time.Second,
+	"notification.summary.interval": time.Hour,
+
+	"monitoring.healthcheck_port": 8080,
+	"monitoring.log_level":        "info",
+
+	"monitoring.logging.encoding":            "json",
+	"monitoring.logging.output_paths":        []string{"stderr"},
+	"monitoring.logging.error_output_paths":  []string{"stderr"},
+	"monitoring.logging.sampling.enabled":    true,
+	"monitoring.logging.sampling.initial":    100,
+	"monitoring.logging.sampling.thereafter": 100,
+}
+
+// defaultMuteHours is used by mute buttons without mute_hours.
+const defaultMuteHours = 4
+
+func setDefaults(v *viper.Viper) {
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+}
+
+// applyDerived fills defaults that depend on other settings.
+func applyDerived(c *Config) {
+	if len(c.Confluence.Intervals) == 0 {
+		c.Confluence.Intervals = c.Intervals
+	}
+	for i, b := range c.MessageCard.LarkSpecific.Buttons {
+		if b.Action == "mute" && b.MuteHours == 0 {
+			c.MessageCard.LarkSpecific.Buttons[i].MuteHours = defaultMuteHours
+		}
+	}
+	restoreIntervalCase(c)
+}
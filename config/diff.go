@@ -0,0 +1,129 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Changes describes how a reloaded configuration differs from the running one.
+type Changes struct {
+	AddedSymbols     []string
+	RemovedSymbols   []string
+	AddedIntervals   []string
+	RemovedIntervals []string
+	// Webhook, MessageCard, Filter, Strategy and LogLevels are applied live
+	Webhook     bool
+	MessageCard bool
+	Filter      bool
+	// Strategy is set when the effective settings of any pair changed
+	Strategy bool
+	// LogLevels is set when monitoring.log_level or a component level changed
+	LogLevels bool
+	// RestartRequired lists changed settings that only take effect after a restart
+	RestartRequired []string
+}
+
+// Empty reports whether nothing changed.
+func (c Changes) Empty() bool {
+	return reflect.DeepEqual(c, Changes{})
+}
+
+// Diff compares two configurations.
+func Diff(old, new *Config) Changes {
+	var c Changes
+	c.AddedSymbols, c.RemovedSymbols = diffSet(old.Symbols, new.Symbols)
+	c.AddedIntervals, c.RemovedIntervals = diffSet(old.Intervals, new.Intervals)
+
+	c.Webhook = !reflect.DeepEqual(old.Webhook, new.Webhook)
+	oldDedup, newDedup := old.Signal.Dedup, new.Signal.Dedup
+	oldDedup.WindowCandles, newDedup.WindowCandles = 0, 0
+	c.Filter = oldDedup != newDedup
+	for _, symbol := range new.Symbols {
+		for _, interval := range new.Intervals {
+			c.Strategy = c.Strategy || old.Strategy(symbol, interval) != new.Strategy(symbol, interval)
+		}
+	}
+
+	// The callback endpoint is registered once at startup
+	oldCard, newCard := old.MessageCard, new.MessageCard
+	if oldCard.LarkSpecific.Callback != newCard.LarkSpecific.Callback {
+		c.RestartRequired = append(c.RestartRequired, "message_card.lark_specific.callback")
+	}
+	oldCard.LarkSpecific.Callback, newCard.LarkSpecific.Callback = LarkCallbackConfig{}, LarkCallbackConfig{}
+	c.MessageCard = !reflect.DeepEqual(oldCard, newCard)
+
+	// Levels are atomic, the rest of the logging setup is built once
+	oldMon, newMon := old.Monitoring, new.Monitoring
+	c.LogLevels = oldMon.LogLevel != newMon.LogLevel || !reflect.DeepEqual(oldMon.Logging.Components, newMon.Logging.Components)
+	oldMon.LogLevel, newMon.LogLevel = "", ""
+	oldMon.Logging.Components, newMon.Logging.Components = nil, nil
+
+	restart := []struct {
+		key      string
+		old, new interface{}
+	}{
+		{"binance", old.Binance, new.Binance},
+		{"data", old.Data, new.Data},
+		{"trade_bars", old.TradeBars, new.TradeBars},
+		{"indicators.arithmetic", old.Indicators.Arithmetic, new.Indicators.Arithmetic},
+		{"signal.hysteresis", old.Signal.Hysteresis, new.Signal.Hysteresis},
+		{"risk", old.Risk, new.Risk},
+		{"confluence", old.Confluence, new.Confluence},
+		{"scoring", old.Scoring, new.Scoring},
+		{"paper", old.Paper, new.Paper},
+		{"notification", old.Notification, new.Notification},
+		{"monitoring", oldMon, newMon},
+	}
+	for _, r := range restart {
+		if !reflect.DeepEqual(r.old, r.new) {
+			c.RestartRequired = append(c.RestartRequired, r.key)
+		}
+	}
+	return c
+}
+
+// applied returns the configuration in effect once new is applied to a
+// process running with running: new, except for the settings Diff reports as
+// RestartRequired, which keep their running values.
+func applied(running, new *Config) *Config {
+	cfg := *new
+	cfg.Binance = running.Binance
+	cfg.Data = running.Data
+	cfg.TradeBars = running.TradeBars
+	cfg.Indicators.Arithmetic = running.Indicators.Arithmetic
+	cfg.Signal.Hysteresis = running.Signal.Hysteresis
+	cfg.Risk = running.Risk
+	cfg.Confluence = running.Confluence
+	cfg.Scoring = running.Scoring
+	cfg.Paper = running.Paper
+	cfg.Notification = running.Notification
+	cfg.MessageCard.LarkSpecific.Callback = running.MessageCard.LarkSpecific.Callback
+
+	cfg.Monitoring = running.Monitoring
+	cfg.Monitoring.LogLevel = new.Monitoring.LogLevel
+	cfg.Monitoring.Logging.Components = new.Monitoring.Logging.Components
+	return &cfg
+}
+
+// diffSet returns the entries only in b and the entries only in a, sorted.
+func diffSet(a, b []string) (added, removed []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
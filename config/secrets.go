@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"fibo-monitor/redact"
+)
+
+// Fields tagged secret:"true" hold credentials. Instead of the value itself
+// they may reference it as env:NAME or file:/path, e.g. a Docker or
+// Kubernetes secret mounted as a file.
+const (
+	envPrefix  = "env:"
+	filePrefix = "file:"
+)
+
+// resolveSecrets replaces secret references with their values.
+func resolveSecrets(c *Config) ValidationErrors {
+	var errs ValidationErrors
+	eachSecret(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.Value) {
+		ref := field.String()
+		switch {
+		case strings.HasPrefix(ref, envPrefix):
+			name := strings.TrimPrefix(ref, envPrefix)
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("environment variable %s is not set", name)})
+				return
+			}
+			field.SetString(strings.TrimSpace(value))
+		case strings.HasPrefix(ref, filePrefix):
+			name := strings.TrimPrefix(ref, filePrefix)
+			data, err := os.ReadFile(name)
+			if err != nil {
+				errs = append(errs, ValidationError{Path: path, Message: err.Error()})
+				return
+			}
+			field.SetString(strings.TrimSpace(string(data)))
+		}
+	})
+	return errs
+}
+
+// Secrets returns the values of all secret fields.
+func (c *Config) Secrets() []string {
+	var values []string
+	eachSecret(reflect.ValueOf(c).Elem(), "", func(_ string, field reflect.Value) {
+		if field.String() != "" {
+			values = append(values, field.String())
+		}
+	})
+	return values
+}
+
+// eachSecret calls fn with the YAML path of every secret field in v.
+func eachSecret(v reflect.Value, path string, fn func(path string, field reflect.Value)) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key, squash := fieldKey(f)
+			fieldPath := joinPath(path, key)
+			if squash {
+				fieldPath = path
+			}
+			if f.Tag.Get("secret") == "true" {
+				fn(fieldPath, v.Field(i))
+				continue
+			}
+			eachSecret(v.Field(i), fieldPath, fn)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			eachSecret(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	}
+}
+
+// Redacted returns the configuration keyed like config.yaml, with secret
+// values replaced, for display.
+func (c *Config) Redacted() map[string]interface{} {
+	return dump(reflect.ValueOf(c).Elem()).(map[string]interface{})
+}
+
+func dump(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key, squash := fieldKey(f)
+			switch {
+			case squash:
+				for k, value := range dump(v.Field(i)).(map[string]interface{}) {
+					m[k] = value
+				}
+			case f.Tag.Get("secret") == "true" && v.Field(i).String() != "":
+				m[key] = redact.Placeholder
+			default:
+				m[key] = dump(v.Field(i))
+			}
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = dump(v.Index(i))
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = dump(iter.Value())
+		}
+		return m
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return v.Interface()
+}
+
+// fieldKey returns the mapstructure key of a field and whether it is squashed
+// into its parent.
+func fieldKey(f reflect.StructField) (string, bool) {
+	name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+	return name, opts == "squash"
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
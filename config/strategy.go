@@ -0,0 +1,111 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// StrategyConfig holds the strategy settings that can differ between pairs.
+type StrategyConfig struct {
+	EmaShortPeriod      int           `mapstructure:"ema_short_period"`
+	EmaLongPeriod       int           `mapstructure:"ema_long_period"`
+	DeduplicationWindow time.Duration `mapstructure:"deduplication_window"`
+	WindowCandles       int           `mapstructure:"window_candles"`
+	MinVolume           float64       `mapstructure:"min_volume"`
+}
+
+// OverridesConfig layers strategy settings over the global indicators and
+// signal settings. Only non-zero fields override. Keys of Pairs are
+// "<symbol>@<interval>", e.g. btcusdt@4h.
+type OverridesConfig struct {
+	Symbols   map[string]StrategyConfig `mapstructure:"symbols"`
+	Intervals map[string]StrategyConfig `mapstructure:"intervals"`
+	Pairs     map[string]StrategyConfig `mapstructure:"pairs"`
+}
+
+// PairKey returns the Overrides.Pairs key of a symbol and interval.
+func PairKey(symbol, interval string) string {
+	return strings.ToLower(symbol) + "@" + interval
+}
+
+// Strategy returns the effective settings of a pair: the global settings,
+// overridden per interval, then per symbol, then per symbol+interval.
+func (c *Config) Strategy(symbol, interval string) StrategyConfig {
+	symbol = strings.ToLower(symbol)
+	s := StrategyConfig{
+		EmaShortPeriod:      c.Indicators.EmaShortPeriod,
+		EmaLongPeriod:       c.Indicators.EmaLongPeriod,
+		DeduplicationWindow: c.Signal.DeduplicationWindow,
+		WindowCandles:       c.Signal.Dedup.WindowCandles,
+		MinVolume:           c.Signal.MinVolume,
+	}
+	for _, o := range []StrategyConfig{
+		c.Overrides.Intervals[interval],
+		c.Overrides.Symbols[symbol],
+		c.Overrides.Pairs[PairKey(symbol, interval)],
+	} {
+		if o.EmaShortPeriod != 0 {
+			s.EmaShortPeriod = o.EmaShortPeriod
+		}
+		if o.EmaLongPeriod != 0 {
+			s.EmaLongPeriod = o.EmaLongPeriod
+		}
+		if o.DeduplicationWindow != 0 {
+			s.DeduplicationWindow = o.DeduplicationWindow
+		}
+		if o.WindowCandles != 0 {
+			s.WindowCandles = o.WindowCandles
+		}
+		if o.MinVolume != 0 {
+			s.MinVolume = o.MinVolume
+		}
+	}
+	return s
+}
+
+// restoreIntervalCase undoes viper lowercasing map keys, which turns an
+// override of the monthly "1M" interval into "1m". Interval keys matching no
+// monitored interval get the spelling of the one they match ignoring case.
+func restoreIntervalCase(c *Config) {
+	intervals := make(map[string]StrategyConfig, len(c.Overrides.Intervals))
+	for key, s := range c.Overrides.Intervals {
+		intervals[intervalKey(c.Intervals, key)] = s
+	}
+	pairs := make(map[string]StrategyConfig, len(c.Overrides.Pairs))
+	for key, s := range c.Overrides.Pairs {
+		if symbol, interval, ok := strings.Cut(key, "@"); ok {
+			key = symbol + "@" + intervalKey(c.Intervals, interval)
+		}
+		pairs[key] = s
+	}
+	if c.Overrides.Intervals != nil {
+		c.Overrides.Intervals = intervals
+	}
+	if c.Overrides.Pairs != nil {
+		c.Overrides.Pairs = pairs
+	}
+}
+
+func intervalKey(intervals []string, key string) string {
+	if contains(intervals, key) {
+		return key
+	}
+	for _, interval := range intervals {
+		if strings.EqualFold(interval, key) {
+			return interval
+		}
+	}
+	return key
+}
+
+// ambiguousInterval reports whether key, lowercased by viper, may name more
+// than one monitored interval, as "1m" does when "1M" is monitored too.
+func ambiguousInterval(intervals []string, key string) bool {
+	n := 0
+	for _, interval := range intervals {
+		if strings.EqualFold(interval, key) {
+			n++
+		}
+	}
+	return n > 1
+}
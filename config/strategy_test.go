@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadReplaced(t *testing.T, replace ...string) (*Config, error) {
+	t.Helper()
+	shipped, err := os.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := strings.NewReplacer(replace...).Replace(string(shipped))
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(path)
+}
+
+func TestMonthlyIntervalOverride(t *testing.T) {
+	override := "  intervals:\n    1M:\n      ema_short_period: 5\n  pairs:\n    btcusdt@1M:\n      ema_long_period: 50\n"
+	cfg, err := loadReplaced(t, `  - "4h"`+"\n", `  - "4h"`+"\n"+`  - "1M"`+"\n", "  intervals: {}\n", override, "  pairs: {}\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := cfg.Strategy("btcusdt", "1M"); s.EmaShortPeriod != 5 || s.EmaLongPeriod != 50 {
+		t.Fatalf("1M strategy = %+v", s)
+	}
+
+	// With 1m monitored as well the key could mean either
+	_, err = loadReplaced(t, `  - "4h"`+"\n", `  - "4h"`+"\n"+`  - "1M"`+"\n"+`  - "1m"`+"\n", "  intervals: {}\n", override, "  pairs: {}\n", "")
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !strings.Contains(err.Error(), "overrides.intervals.1m") || !strings.Contains(err.Error(), "overrides.pairs.btcusdt@1m") {
+		t.Fatalf("ambiguous keys accepted: %v", err)
+	}
+}
+
+func TestWindowCandlesNeedFixedLengthBars(t *testing.T) {
+	bars := "trade_bars:\n  - type: \"time\"\n    period: \"15s\"\n"
+	if _, err := loadReplaced(t, "trade_bars: []\n", bars, "window_candles: 0 ", "window_candles: 3 "); err != nil {
+		t.Fatalf("time bars rejected: %v", err)
+	}
+
+	bars += "  - type: \"tick\"\n    size: 500\n"
+	_, err := loadReplaced(t, "trade_bars: []\n", bars, "window_candles: 0 ", "window_candles: 3 ")
+	if err == nil || !strings.Contains(err.Error(), "trade_bars[1]") {
+		t.Fatalf("window_candles with tick bars accepted: %v", err)
+	}
+	if _, err := loadReplaced(t, "trade_bars: []\n", bars); err != nil {
+		t.Fatalf("tick bars without window_candles rejected: %v", err)
+	}
+}
@@ -0,0 +1,447 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"fibo-monitor/data/kline"
+	"fibo-monitor/redact"
+)
+
+// ValidationError is a single invalid setting.
+type ValidationError struct {
+	Path    string // YAML path, e.g. webhook.channels[1].url
+	Message string
+}
+
+// Error masks secret values, which invalid URLs may contain.
+func (e ValidationError) Error() string {
+	return e.Path + ": " + redact.String(e.Message)
+}
+
+// ValidationErrors collects every invalid setting of a configuration.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("invalid config (%d errors):", len(e)))
+	for _, err := range e {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) addf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// check records an error when ok is false.
+func (v *validator) check(ok bool, path, format string, args ...interface{}) {
+	if !ok {
+		v.addf(path, format, args...)
+	}
+}
+
+var (
+	symbolPattern  = regexp.MustCompile(`^[a-z0-9]+$`)
+	severities     = []string{"info", "warning", "critical"}
+	logLevels      = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+	logEncodings   = []string{"json", "console"}
+	logComponents  = []string{"config", "websocket", "recording", "kline", "signal", "risk", "paper", "notification", "monitor"}
+	buttonActions  = []string{"", "ack", "ignore", "mute", "chart"}
+	tradeBarTypes  = []string{"time", "tick", "volume", "dollar"}
+	riskMethods    = []string{"atr", "fibonacci"}
+	paperSizings   = []string{"notional", "equity_pct", "risk_pct"}
+	arithmeticMode = []string{"float", "decimal"}
+)
+
+// Validate checks every setting and returns ValidationErrors listing all
+// problems found, or nil.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.url("binance.websocket_url", c.Binance.WebsocketURL, "ws", "wss")
+	v.check(c.Binance.ReconnectInterval > 0, "binance.reconnect_interval", "must be positive, got %v", c.Binance.ReconnectInterval)
+	v.check(c.Binance.PingInterval >= 0, "binance.ping_interval", "must not be negative, got %v", c.Binance.PingInterval)
+
+	v.check(len(c.Symbols) > 0, "symbols", "at least one symbol is required")
+	seen := make(map[string]bool)
+	for i, s := range c.Symbols {
+		path := fmt.Sprintf("symbols[%d]", i)
+		v.check(symbolPattern.MatchString(s), path, "%q must be a lowercase symbol such as btcusdt", s)
+		v.check(!seen[s], path, "duplicate symbol %q", s)
+		seen[s] = true
+	}
+
+	v.check(len(c.Intervals) > 0, "intervals", "at least one interval is required")
+	c.validateIntervals(v)
+
+	v.check(c.Data.HistorySize > 0, "data.history_size", "must be positive, got %d", c.Data.HistorySize)
+	if rec := c.Data.Recording; rec.Enabled {
+		v.check(rec.Dir != "", "data.recording.dir", "is required")
+		v.check(rec.RotateInterval >= 0, "data.recording.rotate_interval", "must not be negative, got %v", rec.RotateInterval)
+		v.check(rec.MaxSizeMB >= 0, "data.recording.max_size_mb", "must not be negative, got %d", rec.MaxSizeMB)
+		v.check(rec.MaxFiles >= 0, "data.recording.max_files", "must not be negative, got %d", rec.MaxFiles)
+	}
+
+	for i, b := range c.TradeBars {
+		path := fmt.Sprintf("trade_bars[%d]", i)
+		if !oneOf(b.Type, tradeBarTypes) {
+			v.addf(path+".type", "unknown bar type %q, want one of %s", b.Type, strings.Join(tradeBarTypes, ", "))
+			continue
+		}
+		if b.Type == "time" {
+			v.check(b.Period >= time.Second && b.Period%time.Second == 0, path+".period", "time bars need a period of whole seconds, got %v", b.Period)
+		} else {
+			v.check(b.Size > 0, path+".size", "%s bars need a positive size", b.Type)
+		}
+	}
+
+	ind := c.Indicators
+	v.check(ind.EmaShortPeriod > 0, "indicators.ema_short_period", "must be positive, got %d", ind.EmaShortPeriod)
+	v.check(ind.EmaLongPeriod > ind.EmaShortPeriod, "indicators.ema_long_period",
+		"must be greater than ema_short_period (%d), got %d", ind.EmaShortPeriod, ind.EmaLongPeriod)
+	v.oneOf("indicators.arithmetic", ind.Arithmetic, arithmeticMode)
+
+	sig := c.Signal
+	v.check(sig.DeduplicationWindow >= 0, "signal.deduplication_window", "must not be negative, got %v", sig.DeduplicationWindow)
+	v.check(sig.MinVolume >= 0, "signal.min_volume", "must not be negative, got %v", sig.MinVolume)
+	v.check(sig.Dedup.WindowCandles >= 0, "signal.dedup.window_candles", "must not be negative, got %d", sig.Dedup.WindowCandles)
+	v.check(sig.Dedup.MinPriceMovePct >= 0, "signal.dedup.min_price_move_pct", "must not be negative, got %v", sig.Dedup.MinPriceMovePct)
+	v.check(sig.Dedup.EvictionInterval > 0, "signal.dedup.eviction_interval", "must be positive, got %v", sig.Dedup.EvictionInterval)
+	h := sig.Hysteresis
+	v.check(h.MinSpreadPct >= 0, "signal.hysteresis.min_spread_pct", "must not be negative, got %v", h.MinSpreadPct)
+	v.check(h.MinSpreadATR >= 0, "signal.hysteresis.min_spread_atr", "must not be negative, got %v", h.MinSpreadATR)
+	v.check(h.ATRPeriod > 0, "signal.hysteresis.atr_period", "must be positive, got %d", h.ATRPeriod)
+	v.check(h.ConfirmTicks >= 0, "signal.hysteresis.confirm_ticks", "must not be negative, got %d", h.ConfirmTicks)
+	v.check(h.ConfirmBars >= 0, "signal.hysteresis.confirm_bars", "must not be negative, got %d", h.ConfirmBars)
+	v.check(h.RecordSize >= 0, "signal.hysteresis.record_size", "must not be negative, got %d", h.RecordSize)
+
+	c.validateOverrides(v)
+	c.validateWindowCandles(v)
+
+	if c.Risk.Enabled {
+		v.riskRule("risk", c.Risk.RiskRule, true)
+		intervals := make([]string, 0, len(c.Risk.Intervals))
+		for interval := range c.Risk.Intervals {
+			intervals = append(intervals, interval)
+		}
+		sort.Strings(intervals)
+		for _, interval := range intervals {
+			rule := c.Risk.Intervals[interval]
+			path := "risk.intervals." + interval
+			v.check(contains(c.Intervals, interval), path, "interval %q is not monitored", interval)
+			v.riskRule(path, rule, false)
+		}
+	}
+
+	if c.Confluence.Enabled {
+		v.check(c.Confluence.MinAligned >= 0, "confluence.min_aligned", "must not be negative, got %d", c.Confluence.MinAligned)
+		for i, interval := range c.Confluence.Intervals {
+			_, err := kline.ParseInterval(interval)
+			v.check(err == nil, fmt.Sprintf("confluence.intervals[%d]", i), "unknown interval %q", interval)
+		}
+	}
+
+	if sc := c.Scoring; sc.Enabled {
+		v.check(sc.MinScore >= 0 && sc.MinScore <= 100, "scoring.min_score", "must be between 0 and 100, got %v", sc.MinScore)
+		v.check(sc.WarningScore > 0 && sc.WarningScore <= 100, "scoring.warning_score", "must be between 0 and 100, got %v", sc.WarningScore)
+		v.check(sc.CriticalScore > sc.WarningScore && sc.CriticalScore <= 100, "scoring.critical_score",
+			"must be greater than warning_score (%v) and at most 100, got %v", sc.WarningScore, sc.CriticalScore)
+		v.check(sc.VolumeLookback > 0, "scoring.volume_lookback", "must be positive, got %d", sc.VolumeLookback)
+		v.check(sc.RSIPeriod > 0, "scoring.rsi_period", "must be positive, got %d", sc.RSIPeriod)
+		w := sc.Weights
+		v.check(w.Slope >= 0 && w.Volume >= 0 && w.Distance >= 0 && w.Alignment >= 0 && w.RSI >= 0,
+			"scoring.weights", "weights must not be negative")
+		v.check(w.Slope+w.Volume+w.Distance+w.Alignment+w.RSI > 0 || sc.MinScore == 0, "scoring.min_score",
+			"every weight is 0, so all signals score 0 and none would reach %v", sc.MinScore)
+	}
+
+	c.validatePaper(v)
+	c.validateWebhook(v)
+	c.validateMessageCard(v)
+
+	if n := c.Notification; n.Digest.Enabled {
+		v.check(n.Digest.Window > 0, "notification.digest.window", "must be positive, got %v", n.Digest.Window)
+	}
+	if n := c.Notification; n.Summary.Enabled {
+		v.check(n.Summary.Interval >= time.Minute, "notification.summary.interval", "must be at least 1m, got %v", n.Summary.Interval)
+	}
+
+	v.check(c.Monitoring.HealthcheckPort > 0 && c.Monitoring.HealthcheckPort <= 65535, "monitoring.healthcheck_port",
+		"must be between 1 and 65535, got %d", c.Monitoring.HealthcheckPort)
+	v.oneOf("monitoring.log_level", c.Monitoring.LogLevel, logLevels)
+	c.validateLogging(v)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (c *Config) validateLogging(v *validator) {
+	lc := c.Monitoring.Logging
+	v.oneOf("monitoring.logging.encoding", lc.Encoding, logEncodings)
+	v.check(len(lc.OutputPaths) > 0, "monitoring.logging.output_paths", "at least one output is required")
+	if lc.Sampling.Enabled {
+		v.check(lc.Sampling.Initial > 0, "monitoring.logging.sampling.initial", "must be positive, got %d", lc.Sampling.Initial)
+		v.check(lc.Sampling.Thereafter >= 0, "monitoring.logging.sampling.thereafter", "must not be negative, got %d", lc.Sampling.Thereafter)
+	}
+	for _, name := range sortedKeys(lc.Components) {
+		path := "monitoring.logging.components." + name
+		v.check(contains(logComponents, name), path, "unknown component %q, want one of %s", name, strings.Join(logComponents, ", "))
+		v.oneOf(path, lc.Components[name], logLevels)
+	}
+}
+
+// validatePaper checks the simulated account, sizing and costs.
+func (c *Config) validatePaper(v *validator) {
+	p := c.Paper
+	if !p.Enabled {
+		return
+	}
+	v.check(p.InitialBalance > 0, "paper.initial_balance", "must be positive, got %v", p.InitialBalance)
+	v.oneOf("paper.sizing", p.Sizing, paperSizings)
+	v.check(p.Size > 0, "paper.size", "must be positive, got %v", p.Size)
+	v.check(p.Sizing != "risk_pct" || c.Risk.Enabled, "paper.sizing", "risk_pct needs risk.enabled for stop levels")
+	v.check(p.MaxLeverage >= 0, "paper.max_leverage", "must not be negative, got %v", p.MaxLeverage)
+	v.check(p.FeePct >= 0, "paper.fee_pct", "must not be negative, got %v", p.FeePct)
+	v.check(p.SlippagePct >= 0, "paper.slippage_pct", "must not be negative, got %v", p.SlippagePct)
+	for i, interval := range p.Intervals {
+		v.check(contains(c.Intervals, interval), fmt.Sprintf("paper.intervals[%d]", i), "interval %q is not monitored", interval)
+	}
+	v.check(p.EquityInterval > 0, "paper.equity_interval", "must be positive, got %v", p.EquityInterval)
+	v.check(p.EquityPoints > 0, "paper.equity_points", "must be positive, got %d", p.EquityPoints)
+	if p.DailySummary {
+		_, err := time.LoadLocation(p.Timezone)
+		v.check(err == nil, "paper.timezone", "unknown time zone %q, use an IANA name such as Asia/Shanghai", p.Timezone)
+		v.check(c.Webhook.Enabled, "paper.daily_summary", "needs webhook.enabled to deliver the summary")
+	}
+}
+
+// validateOverrides checks the override keys and the effective strategy of
+// every monitored pair.
+func (c *Config) validateOverrides(v *validator) {
+	o := c.Overrides
+	for _, key := range sortedKeys(o.Symbols) {
+		path := "overrides.symbols." + key
+		v.check(contains(c.Symbols, key), path, "symbol %q is not monitored", key)
+		v.strategy(path, o.Symbols[key])
+	}
+	for _, key := range sortedKeys(o.Intervals) {
+		path := "overrides.intervals." + key
+		v.check(contains(c.Intervals, key), path, "interval %q is not monitored", key)
+		v.check(!ambiguousInterval(c.Intervals, key), path, "keys are read in lower case, so an override of %q cannot be told apart from one of %q", key, strings.ToUpper(key))
+		v.strategy(path, o.Intervals[key])
+	}
+	for _, key := range sortedKeys(o.Pairs) {
+		path := "overrides.pairs." + key
+		symbol, interval, ok := strings.Cut(key, "@")
+		v.check(ok && contains(c.Symbols, symbol) && contains(c.Intervals, interval), path,
+			"%q must be <symbol>@<interval> of a monitored symbol and interval, e.g. btcusdt@4h", key)
+		v.check(!ambiguousInterval(c.Intervals, interval), path, "keys are read in lower case, so an override of %q cannot be told apart from one of %q", key, symbol+"@"+strings.ToUpper(interval))
+		v.strategy(path, o.Pairs[key])
+	}
+
+	for _, symbol := range c.Symbols {
+		for _, interval := range c.Intervals {
+			s := c.Strategy(symbol, interval)
+			v.check(s.EmaShortPeriod > 0 && s.EmaLongPeriod > s.EmaShortPeriod, "overrides",
+				"%s has ema periods %d/%d, the short period must be positive and below the long one",
+				PairKey(symbol, interval), s.EmaShortPeriod, s.EmaLongPeriod)
+		}
+	}
+}
+
+// validateWindowCandles rejects window_candles for bars without a fixed
+// length, where a window cannot be counted in candles.
+func (c *Config) validateWindowCandles(v *validator) {
+	for i, b := range c.TradeBars {
+		if b.Type == "time" || !oneOf(b.Type, tradeBarTypes) {
+			continue
+		}
+		interval := kline.BarSpec{Type: kline.BarType(b.Type), Size: b.Size}.Interval()
+		for _, symbol := range c.Symbols {
+			if s := c.Strategy(symbol, interval); s.WindowCandles > 0 {
+				v.addf(fmt.Sprintf("trade_bars[%d]", i), "%s bars have no fixed length, so window_candles cannot apply to %s; use deduplication_window instead",
+					b.Type, PairKey(symbol, interval))
+				break
+			}
+		}
+	}
+}
+
+func (v *validator) strategy(path string, s StrategyConfig) {
+	v.check(s.EmaShortPeriod >= 0, path+".ema_short_period", "must not be negative, got %d", s.EmaShortPeriod)
+	v.check(s.EmaLongPeriod >= 0, path+".ema_long_period", "must not be negative, got %d", s.EmaLongPeriod)
+	v.check(s.DeduplicationWindow >= 0, path+".deduplication_window", "must not be negative, got %v", s.DeduplicationWindow)
+	v.check(s.WindowCandles >= 0, path+".window_candles", "must not be negative, got %d", s.WindowCandles)
+	v.check(s.MinVolume >= 0, path+".min_volume", "must not be negative, got %v", s.MinVolume)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateIntervals checks that every interval is either published by
+// Binance or can be aggregated from the base interval.
+func (c *Config) validateIntervals(v *validator) {
+	base := c.Data.BaseInterval
+	baseSize, err := kline.ParseInterval(base)
+	baseOK := err == nil && kline.IsNative(base) && base != "1M"
+	seen := make(map[string]bool)
+
+	for i, interval := range c.Intervals {
+		path := fmt.Sprintf("intervals[%d]", i)
+		v.check(!seen[interval], path, "duplicate interval %q", interval)
+		seen[interval] = true
+
+		size, err := kline.ParseInterval(interval)
+		if err != nil {
+			v.addf(path, "unknown interval %q, use a number and one of s, m, h, d, w, M (e.g. 15m, 4h)", interval)
+			continue
+		}
+		if kline.IsNative(interval) {
+			continue
+		}
+		if !baseOK {
+			v.addf("data.base_interval", "%q must be a native interval below 1M to build %q", base, interval)
+			continue
+		}
+		v.check(interval[len(interval)-1] != 'M' && size%baseSize == 0 && size > baseSize, path,
+			"%q cannot be built from base interval %q; it must be a whole multiple of it", interval, base)
+	}
+}
+
+func (v *validator) riskRule(path string, r RiskRule, required bool) {
+	if required || r.Method != "" {
+		v.oneOf(path+".method", r.Method, riskMethods)
+	}
+	v.check(r.ATRPeriod > 0 || !required && r.ATRPeriod == 0, path+".atr_period", "must be positive, got %d", r.ATRPeriod)
+	v.check(r.StopLossATR > 0 || !required && r.StopLossATR == 0, path+".stop_loss_atr", "must be positive, got %v", r.StopLossATR)
+	v.check(len(r.TakeProfitATR) > 0 || !required, path+".take_profit_atr", "at least one target is required")
+	for i, tp := range r.TakeProfitATR {
+		v.check(tp > 0, fmt.Sprintf("%s.take_profit_atr[%d]", path, i), "must be positive, got %v", tp)
+	}
+	v.check(r.FibLookback > 1 || !required && r.FibLookback == 0, path+".fib_lookback", "must be greater than 1, got %d", r.FibLookback)
+}
+
+func (c *Config) validateWebhook(v *validator) {
+	w := c.Webhook
+	if !w.Enabled {
+		return
+	}
+	v.check(w.URL != "" || len(w.Channels) > 0, "webhook.url", "webhook is enabled but neither url nor channels are set")
+	if w.URL != "" {
+		v.url("webhook.url", w.URL, "http", "https")
+	}
+	v.oneOf("webhook.min_severity", w.MinSeverity, severities)
+	v.rateLimit("webhook.rate_limit", w.RateLimit)
+	v.quietHours("webhook.quiet_hours", w.QuietHours)
+	v.check(w.Timeout > 0, "webhook.timeout", "must be positive, got %v", w.Timeout)
+	v.check(w.RetryCount >= 0, "webhook.retry_count", "must not be negative, got %d", w.RetryCount)
+	v.check(w.RetryBackoff >= 0, "webhook.retry_backoff", "must not be negative, got %v", w.RetryBackoff)
+
+	names := map[string]bool{"default": true}
+	for i, ch := range w.Channels {
+		path := fmt.Sprintf("webhook.channels[%d]", i)
+		v.check(ch.Name != "", path+".name", "is required")
+		v.check(ch.Name == "" || !names[ch.Name], path+".name", "duplicate channel name %q", ch.Name)
+		names[ch.Name] = true
+		v.url(path+".url", ch.URL, "http", "https")
+		if ch.MinSeverity != "" {
+			v.oneOf(path+".min_severity", ch.MinSeverity, severities)
+		}
+		v.rateLimit(path+".rate_limit", ch.RateLimit)
+		v.quietHours(path+".quiet_hours", ch.QuietHours)
+	}
+}
+
+func (v *validator) rateLimit(path string, r RateLimitConfig) {
+	v.check(r.PerMinute >= 0, path+".per_minute", "must not be negative, got %v", r.PerMinute)
+	v.check(r.Burst >= 0, path+".burst", "must not be negative, got %d", r.Burst)
+}
+
+func (v *validator) quietHours(path string, q QuietHoursConfig) {
+	if !q.Enabled {
+		return
+	}
+	if q.Timezone != "" {
+		_, err := time.LoadLocation(q.Timezone)
+		v.check(err == nil, path+".timezone", "unknown time zone %q, use an IANA name such as Asia/Shanghai", q.Timezone)
+	}
+	for _, f := range []struct{ key, value string }{{"start", q.Start}, {"end", q.End}} {
+		_, err := time.Parse("15:04", f.value)
+		v.check(err == nil, path+"."+f.key, "invalid time of day %q, want HH:MM", f.value)
+	}
+	v.check(q.Start != q.End, path, "start and end must differ")
+	v.oneOf(path+".min_severity", q.MinSeverity, severities)
+}
+
+func (c *Config) validateMessageCard(v *validator) {
+	lark := c.MessageCard.LarkSpecific
+	for i, b := range lark.Buttons {
+		path := fmt.Sprintf("message_card.lark_specific.buttons[%d]", i)
+		v.check(b.Text != "", path+".text", "is required")
+		if !oneOf(b.Action, buttonActions) {
+			v.addf(path+".action", "unknown action %q, want empty (link) or one of ack, ignore, mute, chart", b.Action)
+			continue
+		}
+		if b.Action == "" {
+			v.url(path+".url", b.URL, "http", "https")
+		}
+		if b.Action == "mute" {
+			v.check(b.MuteHours > 0, path+".mute_hours", "must be positive, got %v", b.MuteHours)
+		}
+	}
+	for i, m := range lark.Mentions {
+		path := fmt.Sprintf("message_card.lark_specific.mentions[%d]", i)
+		if m.MinSeverity != "" {
+			v.oneOf(path+".min_severity", m.MinSeverity, severities)
+		}
+		v.check(len(m.Users) > 0 || m.AtAll, path, "needs users or at_all")
+	}
+	if cb := lark.Callback; cb.Enabled {
+		v.check(strings.HasPrefix(cb.Path, "/"), "message_card.lark_specific.callback.path", "must start with /, got %q", cb.Path)
+		v.check(cb.EncryptKey != "" || cb.VerificationToken != "", "message_card.lark_specific.callback",
+			"needs encrypt_key or verification_token, otherwise anyone can act on cards")
+	}
+}
+
+// url checks that raw is an absolute URL with one of the given schemes.
+func (v *validator) url(path, raw string, schemes ...string) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || !oneOf(u.Scheme, schemes) {
+		v.addf(path, "%q is not a valid %s URL", raw, strings.Join(schemes, "/"))
+	}
+}
+
+func (v *validator) oneOf(path, value string, allowed []string) {
+	v.check(oneOf(value, allowed), path, "unknown value %q, want one of %s", value, strings.Join(allowed, ", "))
+}
+
+func oneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	return oneOf(v, list)
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadDebounce collapses the burst of events an editor produces on save.
+const reloadDebounce = 500 * time.Millisecond
+
+// Watcher reloads the configuration file when it changes, or on demand, and
+// hands each valid new configuration to a callback. An invalid file is
+// rejected and the running configuration stays in effect.
+type Watcher struct {
+	path string
+	// file is the last valid configuration read; current is the one in
+	// effect, which keeps the running value of settings needing a restart
+	file     *Config
+	current  *Config
+	onChange func(old, new *Config)
+	timer    *time.Timer
+	mu       sync.Mutex
+	// reloading serializes Reload, which runs from the debounce timer and
+	// on demand, so changes are applied one at a time and in order
+	reloading sync.Mutex
+	logger    *zap.Logger
+}
+
+func NewWatcher(path string, current *Config, onChange func(old, new *Config), logger *zap.Logger) *Watcher {
+	return &Watcher{
+		path:     path,
+		file:     current,
+		current:  current,
+		onChange: onChange,
+		logger:   logger,
+	}
+}
+
+// Start watches the file's directory, so that editors replacing the file
+// by rename are noticed too.
+func (w *Watcher) Start() error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := fw.Add(filepath.Dir(w.path)); err != nil {
+		fw.Close()
+		return err
+	}
+
+	target := filepath.Clean(w.path)
+	go func() {
+		defer fw.Close()
+		for {
+			select {
+			case event, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target || !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename)) {
+					continue
+				}
+				w.mu.Lock()
+				if w.timer != nil {
+					w.timer.Stop()
+				}
+				w.timer = time.AfterFunc(reloadDebounce, w.Reload)
+				w.mu.Unlock()
+			case err, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+				w.logger.Warn("Config watcher error", zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
+
+// Reload reads and validates the file and applies it if anything changed.
+func (w *Watcher) Reload() {
+	w.reloading.Lock()
+	defer w.reloading.Unlock()
+
+	cfg, err := LoadConfig(w.path)
+	if err != nil {
+		w.logger.Error("Rejected config reload, keeping current config", zap.String("path", w.path), zap.Error(err))
+		return
+	}
+
+	w.mu.Lock()
+	if Diff(w.file, cfg).Empty() {
+		w.mu.Unlock()
+		return
+	}
+	w.file = cfg
+	old := w.current
+	w.current = applied(old, cfg)
+	current := w.current
+	w.mu.Unlock()
+
+	w.logger.Info("Config reloaded", zap.String("path", w.path))
+	for _, key := range Diff(old, cfg).RestartRequired {
+		w.logger.Warn("Config change requires a restart to take effect", zap.String("setting", key))
+	}
+	w.onChange(old, current)
+}
+
+// Current returns the configuration in effect. Settings that need a restart
+// keep their startup values until then.
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
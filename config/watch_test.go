@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWatcherKeepsSettingsNeedingRestart(t *testing.T) {
+	shipped, err := os.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(replace ...string) {
+		t.Helper()
+		data := strings.NewReplacer(replace...).Replace(string(shipped))
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write()
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	w := NewWatcher(path, cfg, func(old, new *Config) { calls++ }, zap.NewNop())
+
+	// Removing a symbol applies live, disabling risk needs a restart
+	write(`  - "ethusdt"`+"\n", "", "risk:\n  enabled: true", "risk:\n  enabled: false")
+	w.Reload()
+	current := w.Current()
+	if calls != 1 || len(current.Symbols) != 1 || !current.Risk.Enabled {
+		t.Fatalf("after reload: %d calls, symbols %v, risk enabled %v", calls, current.Symbols, current.Risk.Enabled)
+	}
+
+	// The unchanged file is not reported again, although risk still differs
+	w.Reload()
+	if calls != 1 {
+		t.Fatalf("unchanged file reloaded: %d calls", calls)
+	}
+}
+
+func TestWatcherSerializesReloads(t *testing.T) {
+	shipped, err := os.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(level string) {
+		t.Helper()
+		data := strings.Replace(string(shipped), `log_level: "info"`, `log_level: "`+level+`"`, 1)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("info")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first change triggers a second reload while it is being applied,
+	// as a SIGHUP arriving during a debounced reload would
+	var active, overlaps atomic.Int32
+	second := make(chan struct{})
+	var w *Watcher
+	w = NewWatcher(path, cfg, func(old, new *Config) {
+		if active.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer active.Add(-1)
+		if new.Monitoring.LogLevel == "debug" {
+			write("warn")
+			go func() {
+				defer close(second)
+				w.Reload()
+			}()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}, zap.NewNop())
+
+	write("debug")
+	w.Reload()
+	<-second
+
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("onChange ran concurrently %d times", n)
+	}
+	if level := w.Current().Monitoring.LogLevel; level != "warn" {
+		t.Errorf("log level after both reloads = %q, want warn", level)
+	}
+}
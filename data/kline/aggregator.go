@@ -0,0 +1,229 @@
+package kline
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Aggregator builds candles for non-native intervals out of a smaller base interval.
+// Aggregated events look exactly like exchange klines to downstream stages.
+type Aggregator struct {
+	base     string
+	baseSize time.Duration
+	targets  []target
+	// forwardBase keeps base interval events in the stream when the base
+	// interval is itself monitored.
+	forwardBase bool
+	// buckets: symbol -> interval -> *bucket
+	buckets map[string]map[string]*bucket
+	mu      sync.Mutex
+	logger  *zap.Logger
+}
+
+type target struct {
+	interval string
+	size     time.Duration
+}
+
+// bucket accumulates the closed base candles of one aggregated candle.
+type bucket struct {
+	start    time.Time
+	end      time.Time
+	merged   *KlineEvent // closed base candles so far
+	lastBase time.Time
+}
+
+// NewAggregator returns an Aggregator producing the given custom intervals from base.
+// Every custom interval must be a whole multiple of base.
+func NewAggregator(base string, intervals []string, forwardBase bool, logger *zap.Logger) (*Aggregator, error) {
+	if !IsNative(base) || base == "1M" {
+		return nil, fmt.Errorf("base interval %q is not a native interval", base)
+	}
+	baseSize, err := ParseInterval(base)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Aggregator{
+		base:        base,
+		baseSize:    baseSize,
+		forwardBase: forwardBase,
+		buckets:     make(map[string]map[string]*bucket),
+		logger:      logger,
+	}
+	for _, interval := range intervals {
+		size, err := ParseInterval(interval)
+		if err != nil {
+			return nil, err
+		}
+		if interval[len(interval)-1] == 'M' || size%baseSize != 0 || size <= baseSize {
+			return nil, fmt.Errorf("interval %q cannot be aggregated from %q", interval, base)
+		}
+		a.targets = append(a.targets, target{interval: interval, size: size})
+	}
+	return a, nil
+}
+
+func (a *Aggregator) Run(inChan <-chan KlineEvent) <-chan KlineEvent {
+	outChan := make(chan KlineEvent, 100)
+
+	go func() {
+		defer close(outChan)
+		for event := range inChan {
+			if event.Kline.Interval != a.base {
+				outChan <- event
+				continue
+			}
+			if a.forwardBase {
+				outChan <- event
+			}
+			var out []KlineEvent
+			a.mu.Lock()
+			for _, t := range a.targets {
+				out = append(out, a.add(t, event)...)
+			}
+			a.mu.Unlock()
+			for _, e := range out {
+				outChan <- e
+			}
+		}
+	}()
+
+	return outChan
+}
+
+// Drop discards the partial candles of a symbol no longer monitored.
+func (a *Aggregator) Drop(symbol string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.buckets, symbol)
+}
+
+// add folds a base event into the target's bucket and returns the events to emit.
+func (a *Aggregator) add(t target, event KlineEvent) []KlineEvent {
+	if _, ok := a.buckets[event.Symbol]; !ok {
+		a.buckets[event.Symbol] = make(map[string]*bucket)
+	}
+
+	var out []KlineEvent
+	b := a.buckets[event.Symbol][t.interval]
+	start := event.Candle.StartTime
+
+	if b != nil && !start.Before(b.end) {
+		// The base candle that would have closed the bucket never arrived
+		if b.merged != nil {
+			a.logger.Warn("Closing incomplete aggregated candle",
+				zap.String("symbol", event.Symbol),
+				zap.String("interval", t.interval),
+				zap.Time("start", b.start),
+			)
+			closed := *b.merged
+			closed.Kline.IsClosed = true
+			closed.Candle.IsClosed = true
+			out = append(out, closed)
+		}
+		b = nil
+	}
+	if b != nil && start.Before(b.start) {
+		// Late tick for a bucket that has already been emitted
+		return out
+	}
+	if b == nil {
+		bStart := AlignStart(start, t.size)
+		b = &bucket{start: bStart, end: bStart.Add(t.size)}
+		a.buckets[event.Symbol][t.interval] = b
+	}
+	if !start.After(b.lastBase) {
+		// Repeated close, or a late tick, of a base candle already folded in
+		return out
+	}
+
+	current := merge(b, t.interval, event)
+	if event.Kline.IsClosed {
+		b.merged = &current
+		b.lastBase = start
+	}
+
+	baseEnd := start.Add(a.baseSize)
+	if event.Kline.IsClosed && !baseEnd.Before(b.end) {
+		// Keep an empty successor so late ticks for this bucket are dropped
+		a.buckets[event.Symbol][t.interval] = &bucket{start: b.end, end: b.end.Add(t.size)}
+	} else {
+		current.Kline.IsClosed = false
+		current.Candle.IsClosed = false
+	}
+	return append(out, current)
+}
+
+// merge combines the closed base candles of the bucket with the given base event.
+func merge(b *bucket, interval string, event KlineEvent) KlineEvent {
+	k := event.Kline
+	c := event.Candle
+
+	out := KlineEvent{
+		Event:  event.Event,
+		Time:   event.Time,
+		Symbol: event.Symbol,
+	}
+	out.Kline = Kline{
+		StartTime:    b.start.UnixMilli(),
+		CloseTime:    b.end.UnixMilli() - 1,
+		Symbol:       k.Symbol,
+		Interval:     interval,
+		FirstTradeID: k.FirstTradeID,
+		LastTradeID:  k.LastTradeID,
+		Open:         k.Open,
+		Close:        k.Close,
+		High:         k.High,
+		Low:          k.Low,
+		IsClosed:     k.IsClosed,
+	}
+	out.Candle = Candle{
+		Symbol:    c.Symbol,
+		Interval:  interval,
+		StartTime: b.start,
+		CloseTime: b.end.Add(-time.Millisecond),
+		Open:      c.Open,
+		High:      c.High,
+		Low:       c.Low,
+		Close:     c.Close,
+		IsClosed:  c.IsClosed,
+	}
+
+	volume, quoteVolume := c.Volume, c.QuoteVolume
+	takerBase, takerQuote := c.TakerBuyBase, c.TakerBuyQuote
+	trades := c.Trades
+
+	if prev := b.merged; prev != nil {
+		pk, pc := prev.Kline, prev.Candle
+		out.Kline.FirstTradeID = pk.FirstTradeID
+		out.Kline.Open, out.Candle.Open = pk.Open, pc.Open
+		if pc.High > c.High {
+			out.Kline.High, out.Candle.High = pk.High, pc.High
+		}
+		if pc.Low < c.Low {
+			out.Kline.Low, out.Candle.Low = pk.Low, pc.Low
+		}
+		volume += pc.Volume
+		quoteVolume += pc.QuoteVolume
+		takerBase += pc.TakerBuyBase
+		takerQuote += pc.TakerBuyQuote
+		trades += pc.Trades
+	}
+
+	out.Candle.Volume, out.Kline.Volume = volume, formatFloat(volume)
+	out.Candle.QuoteVolume, out.Kline.QuoteVolume = quoteVolume, formatFloat(quoteVolume)
+	out.Candle.TakerBuyBase, out.Kline.TakerBuyBase = takerBase, formatFloat(takerBase)
+	out.Candle.TakerBuyQuote, out.Kline.TakerBuyQuote = takerQuote, formatFloat(takerQuote)
+	out.Candle.Trades, out.Kline.Trades = trades, trades
+
+	return out
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,170 @@
+package kline_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"fibo-monitor/data/kline"
+
+	"go.uber.org/zap"
+)
+
+var aggStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// baseEvent is a 1m BTCUSDT event starting minute minutes after aggStart.
+func baseEvent(t *testing.T, minute int, price, volume float64, closed bool) kline.KlineEvent {
+	t.Helper()
+	start := aggStart.Add(time.Duration(minute) * time.Minute)
+	p := strconv.FormatFloat(price, 'f', -1, 64)
+	k := kline.Kline{
+		StartTime: start.UnixMilli(),
+		CloseTime: start.Add(time.Minute).UnixMilli() - 1,
+		Symbol:    "BTCUSDT",
+		Interval:  "1m",
+		Open:      p,
+		High:      strconv.FormatFloat(price+1, 'f', -1, 64),
+		Low:       strconv.FormatFloat(price-1, 'f', -1, 64),
+		Close:     p,
+		Volume:    strconv.FormatFloat(volume, 'f', -1, 64),
+		IsClosed:  closed,
+	}
+	c, err := k.ToCandle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kline.KlineEvent{Event: "kline", Time: k.CloseTime, Symbol: "BTCUSDT", Kline: k, Candle: c}
+}
+
+// aggregate runs events through an aggregator and returns the aggregated ones.
+func aggregate(t *testing.T, intervals []string, events ...kline.KlineEvent) []kline.KlineEvent {
+	t.Helper()
+	a, err := kline.NewAggregator("1m", intervals, false, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan kline.KlineEvent, len(events))
+	for _, e := range events {
+		in <- e
+	}
+	close(in)
+
+	var out []kline.KlineEvent
+	for e := range a.Run(in) {
+		out = append(out, e)
+	}
+	return out
+}
+
+func closedOf(events []kline.KlineEvent, interval string) []kline.Candle {
+	var out []kline.Candle
+	for _, e := range events {
+		if e.Candle.Interval == interval && e.Candle.IsClosed {
+			out = append(out, e.Candle)
+		}
+	}
+	return out
+}
+
+func TestAggregatorAlignment(t *testing.T) {
+	// Starting mid-bucket: 00:03 to 00:09
+	var events []kline.KlineEvent
+	for m := 3; m < 10; m++ {
+		events = append(events, baseEvent(t, m, float64(100+m), 1, true))
+	}
+	out := aggregate(t, []string{"5m", "1h"}, events...)
+
+	fives := closedOf(out, "5m")
+	if len(fives) != 2 {
+		t.Fatalf("got %d closed 5m candles, want 2", len(fives))
+	}
+	for i, want := range []time.Time{aggStart, aggStart.Add(5 * time.Minute)} {
+		if !fives[i].StartTime.Equal(want) || !fives[i].CloseTime.Equal(want.Add(5*time.Minute-time.Millisecond)) {
+			t.Errorf("5m candle %d spans %v-%v, want start %v", i, fives[i].StartTime, fives[i].CloseTime, want)
+		}
+	}
+	if c := fives[1]; c.Open != 105 || c.Close != 109 || c.High != 110 || c.Low != 104 || c.Volume != 5 {
+		t.Errorf("5m candle at 00:05 = %+v", c)
+	}
+
+	if hours := closedOf(out, "1h"); len(hours) != 0 {
+		t.Errorf("got %d closed 1h candles before the hour ended", len(hours))
+	}
+	for _, e := range out {
+		if e.Candle.Interval == "1h" && !e.Candle.StartTime.Equal(aggStart) {
+			t.Errorf("1h candle starts at %v, want %v", e.Candle.StartTime, aggStart)
+		}
+	}
+}
+
+func TestAggregatorRejectsMonths(t *testing.T) {
+	// Calendar months are not a fixed multiple of any base interval
+	if _, err := kline.NewAggregator("1m", []string{"1M"}, false, zap.NewNop()); err == nil {
+		t.Error("NewAggregator accepted 1M")
+	}
+	if _, err := kline.NewAggregator("1m", []string{"7m"}, false, zap.NewNop()); err != nil {
+		t.Errorf("NewAggregator rejected 7m: %v", err)
+	}
+}
+
+func TestAggregatorDuplicateClose(t *testing.T) {
+	out := aggregate(t, []string{"5m"},
+		baseEvent(t, 0, 100, 1, true),
+		baseEvent(t, 1, 101, 1, true),
+		baseEvent(t, 2, 102, 1, true),
+		baseEvent(t, 2, 102, 7, true),
+		baseEvent(t, 3, 103, 1, true),
+		baseEvent(t, 4, 104, 1, true),
+	)
+	if len(out) != 5 {
+		t.Errorf("got %d events, want 5 with the duplicate dropped", len(out))
+	}
+	fives := closedOf(out, "5m")
+	if len(fives) != 1 || fives[0].Volume != 5 {
+		t.Fatalf("closed 5m candles = %+v, want one with volume 5", fives)
+	}
+}
+
+func TestAggregatorLateOpenTick(t *testing.T) {
+	out := aggregate(t, []string{"5m"},
+		baseEvent(t, 0, 100, 1, true),
+		baseEvent(t, 1, 101, 1, true),
+		// An open tick for a base candle already closed
+		baseEvent(t, 1, 500, 1, false),
+		baseEvent(t, 2, 102, 1, true),
+		baseEvent(t, 3, 103, 1, true),
+		baseEvent(t, 4, 104, 1, true),
+		// An open tick for a bucket already emitted
+		baseEvent(t, 4, 500, 1, false),
+	)
+	if len(out) != 5 {
+		t.Errorf("got %d events, want 5 with the late ticks dropped", len(out))
+	}
+	for _, e := range out {
+		if e.Candle.High >= 500 {
+			t.Errorf("late tick folded into %+v", e.Candle)
+		}
+	}
+}
+
+func TestAggregatorMissingBaseCandle(t *testing.T) {
+	// 00:04 never arrives; 00:05 closes the first bucket
+	out := aggregate(t, []string{"5m"},
+		baseEvent(t, 0, 100, 1, true),
+		baseEvent(t, 1, 101, 1, true),
+		baseEvent(t, 2, 102, 1, true),
+		baseEvent(t, 3, 103, 1, true),
+		baseEvent(t, 5, 105, 1, true),
+	)
+	fives := closedOf(out, "5m")
+	if len(fives) != 1 {
+		t.Fatalf("got %d closed 5m candles, want 1", len(fives))
+	}
+	if c := fives[0]; !c.StartTime.Equal(aggStart) || c.Close != 103 || c.Volume != 4 {
+		t.Errorf("incomplete candle = %+v, want close 103 and volume 4", c)
+	}
+	last := out[len(out)-1].Candle
+	if last.IsClosed || !last.StartTime.Equal(aggStart.Add(5*time.Minute)) || last.Open != 105 {
+		t.Errorf("last event = %+v, want the open 00:05 candle", last)
+	}
+}
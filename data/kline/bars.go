@@ -0,0 +1,252 @@
+package kline
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BarType selects how trades are grouped into bars.
+type BarType string
+
+const (
+	TimeBars   BarType = "time"   // fixed wall-clock period
+	TickBars   BarType = "tick"   // fixed number of aggregated trades
+	VolumeBars BarType = "volume" // fixed base asset volume
+	DollarBars BarType = "dollar" // fixed quote asset (USDT) volume
+)
+
+// BarSpec describes one kind of trade-built bar.
+type BarSpec struct {
+	Type   BarType
+	Size   float64       // threshold for tick, volume and dollar bars
+	Period time.Duration // bar length for time bars
+}
+
+// Interval is the name under which bars of this spec appear in KlineEvents,
+// e.g. "t15s", "tick100", "volume50", "dollar1000000". Time bars carry a "t"
+// prefix so they never share a name, and with it detector state, with an
+// exchange interval such as "1m" or "1s".
+func (s BarSpec) Interval() string {
+	if s.Type == TimeBars {
+		if s.Period%time.Minute == 0 {
+			return fmt.Sprintf("t%dm", int64(s.Period/time.Minute))
+		}
+		return fmt.Sprintf("t%ds", int64(s.Period/time.Second))
+	}
+	return string(s.Type) + strconv.FormatFloat(s.Size, 'f', -1, 64)
+}
+
+func (s BarSpec) validate() error {
+	switch s.Type {
+	case TimeBars:
+		if s.Period < time.Second || s.Period%time.Second != 0 {
+			return fmt.Errorf("time bars need a period of whole seconds, got %v", s.Period)
+		}
+	case TickBars, VolumeBars, DollarBars:
+		if s.Size <= 0 {
+			return fmt.Errorf("%s bars need a positive size", s.Type)
+		}
+	default:
+		return fmt.Errorf("unknown bar type %q", s.Type)
+	}
+	return nil
+}
+
+// BarBuilder turns aggregated trades into OHLCV bars delivered as KlineEvents,
+// so the detector treats them like exchange klines.
+type BarBuilder struct {
+	specs []BarSpec
+	// bars: symbol -> interval -> *tradeBar
+	bars map[string]map[string]*tradeBar
+	mu   sync.Mutex
+}
+
+// tradeBar is the bar currently being built.
+type tradeBar struct {
+	candle       Candle
+	firstTradeID int64
+	lastTradeID  int64
+	ticks        int
+	end          time.Time // time bars only
+}
+
+func NewBarBuilder(specs []BarSpec) (*BarBuilder, error) {
+	for _, spec := range specs {
+		if err := spec.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &BarBuilder{
+		specs: specs,
+		bars:  make(map[string]map[string]*tradeBar),
+	}, nil
+}
+
+func (b *BarBuilder) Run(inChan <-chan AggTradeEvent) <-chan KlineEvent {
+	outChan := make(chan KlineEvent, 100)
+
+	go func() {
+		defer close(outChan)
+		for event := range inChan {
+			var out []KlineEvent
+			b.mu.Lock()
+			for _, spec := range b.specs {
+				out = append(out, b.add(spec, event)...)
+			}
+			b.mu.Unlock()
+			for _, e := range out {
+				outChan <- e
+			}
+		}
+	}()
+
+	return outChan
+}
+
+// Intervals returns the interval names of the bars built.
+func (b *BarBuilder) Intervals() []string {
+	var intervals []string
+	for _, spec := range b.specs {
+		intervals = append(intervals, spec.Interval())
+	}
+	return intervals
+}
+
+// Drop discards the bars being built for a symbol no longer monitored.
+func (b *BarBuilder) Drop(symbol string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.bars, symbol)
+}
+
+// add applies a trade to the spec's current bar and returns the events to emit.
+func (b *BarBuilder) add(spec BarSpec, event AggTradeEvent) []KlineEvent {
+	trade := event.Trade
+	interval := spec.Interval()
+
+	if _, ok := b.bars[trade.Symbol]; !ok {
+		b.bars[trade.Symbol] = make(map[string]*tradeBar)
+	}
+
+	var out []KlineEvent
+	bar := b.bars[trade.Symbol][interval]
+
+	if bar != nil && spec.Type == TimeBars && !trade.Time.Before(bar.end) {
+		// Time bars close on the first trade past their end
+		bar.candle.IsClosed = true
+		out = append(out, bar.event(event))
+		bar = nil
+	}
+
+	if bar == nil {
+		bar = &tradeBar{
+			candle: Candle{
+				Symbol:    trade.Symbol,
+				Interval:  interval,
+				StartTime: trade.Time,
+				Open:      trade.Price,
+				High:      trade.Price,
+				Low:       trade.Price,
+			},
+			firstTradeID: trade.FirstTradeID,
+		}
+		if spec.Type == TimeBars {
+			bar.candle.StartTime = AlignStart(trade.Time, spec.Period)
+			bar.end = bar.candle.StartTime.Add(spec.Period)
+		}
+		b.bars[trade.Symbol][interval] = bar
+	}
+
+	c := &bar.candle
+	if trade.Price > c.High {
+		c.High = trade.Price
+	}
+	if trade.Price < c.Low {
+		c.Low = trade.Price
+	}
+	c.Close = trade.Price
+	c.Volume += trade.Quantity
+	c.QuoteVolume += trade.Quantity * trade.Price
+	if !trade.IsBuyerMaker {
+		c.TakerBuyBase += trade.Quantity
+		c.TakerBuyQuote += trade.Quantity * trade.Price
+	}
+	c.Trades += trade.LastTradeID - trade.FirstTradeID + 1
+	bar.lastTradeID = trade.LastTradeID
+	bar.ticks++
+
+	if spec.Type == TimeBars {
+		c.CloseTime = bar.end.Add(-time.Millisecond)
+	} else {
+		c.CloseTime = trade.Time
+	}
+
+	var full bool
+	switch spec.Type {
+	case TickBars:
+		full = float64(bar.ticks) >= spec.Size
+	case VolumeBars:
+		full = c.Volume >= spec.Size
+	case DollarBars:
+		full = c.QuoteVolume >= spec.Size
+	}
+	if full {
+		c.IsClosed = true
+		delete(b.bars[trade.Symbol], interval)
+	}
+
+	return append(out, bar.event(event))
+}
+
+// event renders the bar as a kline event stamped with the trade event time.
+func (bar *tradeBar) event(trade AggTradeEvent) KlineEvent {
+	c := bar.candle
+	return KlineEvent{
+		Event:  "kline",
+		Time:   trade.Time,
+		Symbol: c.Symbol,
+		Kline: Kline{
+			StartTime:     c.StartTime.UnixMilli(),
+			CloseTime:     c.CloseTime.UnixMilli(),
+			Symbol:        c.Symbol,
+			Interval:      c.Interval,
+			FirstTradeID:  bar.firstTradeID,
+			LastTradeID:   bar.lastTradeID,
+			Open:          formatFloat(c.Open),
+			Close:         formatFloat(c.Close),
+			High:          formatFloat(c.High),
+			Low:           formatFloat(c.Low),
+			Volume:        formatFloat(c.Volume),
+			Trades:        c.Trades,
+			IsClosed:      c.IsClosed,
+			QuoteVolume:   formatFloat(c.QuoteVolume),
+			TakerBuyBase:  formatFloat(c.TakerBuyBase),
+			TakerBuyQuote: formatFloat(c.TakerBuyQuote),
+		},
+		Candle: c,
+	}
+}
+
+// Merge fans multiple kline streams into one. The output closes once all inputs are closed.
+func Merge(inChans ...<-chan KlineEvent) <-chan KlineEvent {
+	outChan := make(chan KlineEvent, 100)
+
+	var wg sync.WaitGroup
+	for _, in := range inChans {
+		wg.Add(1)
+		go func(in <-chan KlineEvent) {
+			defer wg.Done()
+			for event := range in {
+				outChan <- event
+			}
+		}(in)
+	}
+	go func() {
+		wg.Wait()
+		close(outChan)
+	}()
+
+	return outChan
+}
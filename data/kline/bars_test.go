@@ -0,0 +1,144 @@
+package kline_test
+
+import (
+	"testing"
+	"time"
+
+	"fibo-monitor/data/kline"
+)
+
+var barStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// tradeAt is a BTCUSDT trade ms milliseconds after barStart.
+func tradeAt(ms int, price, quantity float64) kline.AggTradeEvent {
+	at := barStart.Add(time.Duration(ms) * time.Millisecond)
+	return kline.AggTradeEvent{
+		Event:  "aggTrade",
+		Time:   at.UnixMilli(),
+		Symbol: "BTCUSDT",
+		Trade: kline.Trade{
+			Symbol:       "BTCUSDT",
+			FirstTradeID: int64(ms),
+			LastTradeID:  int64(ms),
+			Price:        price,
+			Quantity:     quantity,
+			Time:         at,
+		},
+	}
+}
+
+// buildBars runs trades through a builder of one spec and returns the
+// closed bars.
+func buildBars(t *testing.T, spec kline.BarSpec, trades ...kline.AggTradeEvent) []kline.Candle {
+	t.Helper()
+	b, err := kline.NewBarBuilder([]kline.BarSpec{spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan kline.AggTradeEvent, len(trades))
+	for _, trade := range trades {
+		in <- trade
+	}
+	close(in)
+
+	var closed []kline.Candle
+	for e := range b.Run(in) {
+		if e.Candle.Interval != spec.Interval() {
+			t.Fatalf("event interval %q, want %q", e.Candle.Interval, spec.Interval())
+		}
+		if e.Candle.IsClosed {
+			closed = append(closed, e.Candle)
+		}
+	}
+	return closed
+}
+
+func TestBarIntervalNames(t *testing.T) {
+	tests := []struct {
+		spec kline.BarSpec
+		want string
+	}{
+		{kline.BarSpec{Type: kline.TimeBars, Period: 15 * time.Second}, "t15s"},
+		{kline.BarSpec{Type: kline.TimeBars, Period: 5 * time.Minute}, "t5m"},
+		{kline.BarSpec{Type: kline.TickBars, Size: 500}, "tick500"},
+		{kline.BarSpec{Type: kline.VolumeBars, Size: 2.5}, "volume2.5"},
+		{kline.BarSpec{Type: kline.DollarBars, Size: 5000000}, "dollar5000000"},
+	}
+	for _, tt := range tests {
+		if got := tt.spec.Interval(); got != tt.want {
+			t.Errorf("Interval() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestTickBars(t *testing.T) {
+	bars := buildBars(t, kline.BarSpec{Type: kline.TickBars, Size: 3},
+		tradeAt(0, 100, 1),
+		tradeAt(10, 103, 1),
+		tradeAt(20, 99, 1),
+		tradeAt(30, 101, 2),
+	)
+	if len(bars) != 1 {
+		t.Fatalf("got %d closed bars, want 1", len(bars))
+	}
+	c := bars[0]
+	if c.Open != 100 || c.High != 103 || c.Low != 99 || c.Close != 99 || c.Volume != 3 || c.Trades != 3 {
+		t.Errorf("tick bar = %+v", c)
+	}
+	if !c.StartTime.Equal(barStart) || !c.CloseTime.Equal(barStart.Add(20*time.Millisecond)) {
+		t.Errorf("tick bar spans %v-%v", c.StartTime, c.CloseTime)
+	}
+}
+
+func TestVolumeBarTradeCrossingThreshold(t *testing.T) {
+	// The second trade crosses the threshold and is not split: it closes
+	// the bar whole, and the next bar starts with the next trade
+	bars := buildBars(t, kline.BarSpec{Type: kline.VolumeBars, Size: 10},
+		tradeAt(0, 100, 6),
+		tradeAt(10, 101, 7),
+		tradeAt(20, 102, 4),
+		tradeAt(30, 103, 6),
+	)
+	if len(bars) != 2 {
+		t.Fatalf("got %d closed bars, want 2", len(bars))
+	}
+	if c := bars[0]; c.Volume != 13 || c.Close != 101 {
+		t.Errorf("first bar = %+v, want volume 13 closing at 101", c)
+	}
+	if c := bars[1]; c.Volume != 10 || c.Open != 102 || c.Close != 103 {
+		t.Errorf("second bar = %+v, want volume 10 from 102 to 103", c)
+	}
+}
+
+func TestDollarBars(t *testing.T) {
+	bars := buildBars(t, kline.BarSpec{Type: kline.DollarBars, Size: 1000},
+		tradeAt(0, 100, 4),
+		tradeAt(10, 100, 5),
+		tradeAt(20, 200, 1),
+	)
+	if len(bars) != 1 {
+		t.Fatalf("got %d closed bars, want 1", len(bars))
+	}
+	if c := bars[0]; c.QuoteVolume != 1100 || c.Volume != 10 || c.High != 200 {
+		t.Errorf("dollar bar = %+v, want 1100 quote volume", c)
+	}
+}
+
+func TestTimeBars(t *testing.T) {
+	// Trades at 5s, 14.999s and 16s: the third closes the first bar
+	bars := buildBars(t, kline.BarSpec{Type: kline.TimeBars, Period: 15 * time.Second},
+		tradeAt(5000, 100, 1),
+		tradeAt(14999, 102, 1),
+		tradeAt(16000, 101, 1),
+	)
+	if len(bars) != 1 {
+		t.Fatalf("got %d closed bars, want 1", len(bars))
+	}
+	c := bars[0]
+	if c.Interval != "t15s" || !c.StartTime.Equal(barStart) || !c.CloseTime.Equal(barStart.Add(15*time.Second-time.Millisecond)) {
+		t.Errorf("time bar %s spans %v-%v, want t15s over the first 15s", c.Interval, c.StartTime, c.CloseTime)
+	}
+	if c.Open != 100 || c.Close != 102 || c.Volume != 2 {
+		t.Errorf("time bar = %+v", c)
+	}
+}
@@ -0,0 +1,95 @@
+package kline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Candle is a Kline with its numeric fields parsed once.
+type Candle struct {
+	Symbol        string
+	Interval      string
+	StartTime     time.Time
+	CloseTime     time.Time
+	Open          float64
+	High          float64
+	Low           float64
+	Close         float64
+	Volume        float64
+	QuoteVolume   float64
+	TakerBuyBase  float64
+	TakerBuyQuote float64
+	Trades        int64
+	IsClosed      bool
+}
+
+// ToCandle parses the string fields of the kline into a Candle.
+func (k *Kline) ToCandle() (Candle, error) {
+	c := Candle{
+		Symbol:    k.Symbol,
+		Interval:  k.Interval,
+		StartTime: time.UnixMilli(k.StartTime).UTC(),
+		CloseTime: time.UnixMilli(k.CloseTime).UTC(),
+		Trades:    k.Trades,
+		IsClosed:  k.IsClosed,
+	}
+
+	fields := []struct {
+		name     string
+		raw      string
+		dst      *float64
+		required bool
+	}{
+		{"open", k.Open, &c.Open, true},
+		{"high", k.High, &c.High, true},
+		{"low", k.Low, &c.Low, true},
+		{"close", k.Close, &c.Close, true},
+		{"volume", k.Volume, &c.Volume, true},
+		{"quote volume", k.QuoteVolume, &c.QuoteVolume, false},
+		{"taker buy base", k.TakerBuyBase, &c.TakerBuyBase, false},
+		{"taker buy quote", k.TakerBuyQuote, &c.TakerBuyQuote, false},
+	}
+	for _, f := range fields {
+		// Volume breakdowns may be absent in some payloads
+		if f.raw == "" && !f.required {
+			continue
+		}
+		v, err := strconv.ParseFloat(f.raw, 64)
+		if err != nil {
+			return Candle{}, fmt.Errorf("invalid %s %q: %w", f.name, f.raw, err)
+		}
+		*f.dst = v
+	}
+
+	return c, nil
+}
+
+// Validate checks the invariants every exchange candle must satisfy.
+func (c Candle) Validate() error {
+	if c.Symbol == "" || c.Interval == "" {
+		return fmt.Errorf("missing symbol or interval")
+	}
+	for _, v := range []float64{c.Open, c.High, c.Low, c.Close} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v <= 0 {
+			return fmt.Errorf("non-positive or non-finite price %v", v)
+		}
+	}
+	if c.Low > c.High {
+		return fmt.Errorf("low %v above high %v", c.Low, c.High)
+	}
+	if c.Open < c.Low || c.Open > c.High {
+		return fmt.Errorf("open %v outside [%v, %v]", c.Open, c.Low, c.High)
+	}
+	if c.Close < c.Low || c.Close > c.High {
+		return fmt.Errorf("close %v outside [%v, %v]", c.Close, c.Low, c.High)
+	}
+	if c.Volume < 0 || c.QuoteVolume < 0 || c.TakerBuyBase < 0 || c.TakerBuyQuote < 0 {
+		return fmt.Errorf("negative volume")
+	}
+	if !c.CloseTime.After(c.StartTime) {
+		return fmt.Errorf("close time %v not after start time %v", c.CloseTime, c.StartTime)
+	}
+	return nil
+}
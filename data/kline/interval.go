@@ -0,0 +1,89 @@
+package kline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nativeIntervals are the kline intervals Binance futures streams publish.
+var nativeIntervals = map[string]bool{
+	"1m": true, "3m": true, "5m": true, "15m": true, "30m": true,
+	"1h": true, "2h": true, "4h": true, "6h": true, "8h": true, "12h": true,
+	"1d": true, "3d": true, "1w": true, "1M": true,
+}
+
+// weekOffset shifts epoch-aligned buckets so weeks start on Monday 00:00 UTC
+// (the Unix epoch is a Thursday).
+const weekOffset = 4 * 24 * time.Hour
+
+// IsNative reports whether the interval can be subscribed to directly.
+func IsNative(interval string) bool {
+	return nativeIntervals[interval]
+}
+
+// ParseInterval converts an interval such as "10m", "3h", "2d" or "1w" into a
+// duration. Months ("M") are approximated as 30 days and are only meaningful
+// for ordering intervals, not for aggregation.
+func ParseInterval(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+
+	var unit time.Duration
+	switch interval[len(interval)-1] {
+	case 's':
+		unit = time.Second
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	case 'M':
+		unit = 30 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid interval unit in %q", interval)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// CandleLength returns the length of one candle of an interval, including
+// time bars built from trades such as "t15s". Tick, volume and dollar bars
+// have no fixed length.
+func CandleLength(interval string) (time.Duration, error) {
+	if strings.HasPrefix(interval, "t") {
+		if size, err := ParseInterval(interval[1:]); err == nil {
+			return size, nil
+		}
+		return 0, fmt.Errorf("%q has no fixed candle length", interval)
+	}
+	return ParseInterval(interval)
+}
+
+// AlignStart returns the UTC start of the bucket of the given size that contains t.
+// Buckets are aligned to the Unix epoch, except weekly multiples which start on Monday.
+func AlignStart(t time.Time, size time.Duration) time.Time {
+	var offset time.Duration
+	if size%(7*24*time.Hour) == 0 {
+		offset = weekOffset
+	}
+	ns := t.UnixNano() - int64(offset)
+	bucket := ns - mod(ns, int64(size))
+	return time.Unix(0, bucket+int64(offset)).UTC()
+}
+
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
@@ -2,50 +2,135 @@ package kline
 
 import (
 	"encoding/json"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 )
 
 type Processor struct {
 	logger *zap.Logger
+	trades chan AggTradeEvent
+	// wantTrades is set once Trades is called; aggTrade frames are skipped
+	// until then, so nothing blocks on a channel nobody reads
+	wantTrades atomic.Bool
+	rejected   atomic.Uint64
 }
 
 func NewProcessor(logger *zap.Logger) *Processor {
 	return &Processor{
 		logger: logger,
+		trades: make(chan AggTradeEvent, 1000),
 	}
 }
 
+// Rejected returns the number of messages dropped because they could not be
+// decoded or failed candle validation.
+func (p *Processor) Rejected() uint64 {
+	return p.rejected.Load()
+}
+
+// Trades returns the aggregated trades seen by Process. Trades are only
+// decoded once it has been called, after which the channel must be drained.
+// It is closed together with the kline channel.
+func (p *Processor) Trades() <-chan AggTradeEvent {
+	p.wantTrades.Store(true)
+	return p.trades
+}
+
 func (p *Processor) Process(msgChan <-chan []byte) <-chan KlineEvent {
 	outChan := make(chan KlineEvent, 100)
 
 	go func() {
 		defer close(outChan)
+		defer close(p.trades)
 		for msg := range msgChan {
 			var event struct {
 				Stream string          `json:"stream"`
 				Data   json.RawMessage `json:"data"`
 			}
 			// Handling combined stream format: {"stream":"<streamName>","data":<payload>}
-			if err := json.Unmarshal(msg, &event); err != nil {
+			if err := json.Unmarshal(msg, &event); err != nil || len(event.Data) == 0 {
 				// Fallback to direct payload if not combined stream (though we use combined)
-				var klineEvent KlineEvent
-				if err2 := json.Unmarshal(msg, &klineEvent); err2 == nil {
-					outChan <- klineEvent
-				} else {
-					p.logger.Error("Failed to unmarshal message", zap.Error(err), zap.String("msg", string(msg)))
-				}
+				p.dispatch(outChan, msg)
 				continue
 			}
-			
-			var klineEvent KlineEvent
-			if err := json.Unmarshal(event.Data, &klineEvent); err != nil {
-				p.logger.Error("Failed to unmarshal kline event", zap.Error(err))
-				continue
-			}
-			outChan <- klineEvent
+			p.dispatch(outChan, event.Data)
 		}
 	}()
 
 	return outChan
 }
+
+// dispatch decodes a single stream payload according to its event type.
+func (p *Processor) dispatch(outChan chan<- KlineEvent, payload []byte) {
+	var head struct {
+		Event string `json:"e"`
+		// Matched case-insensitively to Event otherwise
+		Time int64 `json:"E"`
+		// Replies to SUBSCRIBE/UNSUBSCRIBE requests carry an id instead
+		ID    *int64          `json:"id"`
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &head); err != nil {
+		p.reject("Failed to unmarshal message", zap.Error(err), zap.String("msg", string(payload)))
+		return
+	}
+
+	if head.Event == "" && head.ID != nil {
+		if len(head.Error) > 0 {
+			p.logger.Error("Stream request failed", zap.Int64("id", *head.ID), zap.String("error", string(head.Error)))
+		}
+		return
+	}
+
+	switch head.Event {
+	case "kline":
+		var klineEvent KlineEvent
+		if err := json.Unmarshal(payload, &klineEvent); err != nil {
+			p.reject("Failed to unmarshal kline event", zap.Error(err))
+			return
+		}
+		p.emit(outChan, klineEvent)
+	case "aggTrade":
+		if !p.wantTrades.Load() {
+			return
+		}
+		var tradeEvent AggTradeEvent
+		if err := json.Unmarshal(payload, &tradeEvent); err != nil {
+			p.reject("Failed to unmarshal aggTrade event", zap.Error(err))
+			return
+		}
+		trade, err := tradeEvent.ToTrade()
+		if err != nil {
+			p.reject("Rejected invalid aggTrade", zap.Error(err), zap.String("symbol", tradeEvent.Symbol))
+			return
+		}
+		tradeEvent.Trade = trade
+		p.trades <- tradeEvent
+	default:
+		p.reject("Unsupported message", zap.String("msg", string(payload)))
+	}
+}
+
+// emit parses and validates the kline before handing it downstream.
+func (p *Processor) emit(outChan chan<- KlineEvent, event KlineEvent) {
+	candle, err := event.Kline.ToCandle()
+	if err == nil {
+		err = candle.Validate()
+	}
+	if err != nil {
+		p.reject("Rejected invalid kline",
+			zap.Error(err),
+			zap.String("symbol", event.Symbol),
+			zap.String("interval", event.Kline.Interval),
+		)
+		return
+	}
+	event.Candle = candle
+	outChan <- event
+}
+
+func (p *Processor) reject(msg string, fields ...zap.Field) {
+	total := p.rejected.Add(1)
+	p.logger.Warn(msg, append(fields, zap.Uint64("rejected_total", total))...)
+}
@@ -0,0 +1,63 @@
+package kline_test
+
+import (
+	"testing"
+	"time"
+
+	"fibo-monitor/data/kline"
+	"fibo-monitor/data/websocket"
+	"fibo-monitor/data/websocket/binancetest"
+
+	"go.uber.org/zap"
+)
+
+func TestProcessorRejectsMalformedFrames(t *testing.T) {
+	server := binancetest.NewServer(zap.NewNop())
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	series := binancetest.Series{
+		Symbol:   "btcusdt",
+		Interval: "1m",
+		Start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Bars:     binancetest.Closes([]float64{100, 101, 102}, 10),
+	}
+	payloads, err := series.Payloads()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := websocket.NewClient(server.URL(), time.Second, 0, zap.NewNop())
+	if err := client.Connect([]string{series.Stream()}); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	processor := kline.NewProcessor(zap.NewNop())
+	events := processor.Process(client.Messages())
+
+	for server.Stats().Connections == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	server.Publish(series.Stream(), payloads[0])
+	server.SendRaw([]byte(`{"stream":"btcusdt@kline_1m","data":{"e":"kline",`))
+	server.Publish(series.Stream(), payloads[1])
+	// High below low fails candle validation
+	server.Publish(series.Stream(), []byte(`{"e":"kline","E":1,"s":"BTCUSDT","k":{"t":0,"T":59999,"s":"BTCUSDT","i":"1m","o":"1","c":"1","h":"1","l":"2","v":"1","x":true}}`))
+	server.Publish(series.Stream(), payloads[2])
+
+	for i, want := range []float64{100, 101, 102} {
+		select {
+		case event := <-events:
+			if event.Candle.Close != want {
+				t.Fatalf("event %d close = %v, want %v", i, event.Candle.Close, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %d not received", i)
+		}
+	}
+	if got := processor.Rejected(); got != 2 {
+		t.Fatalf("rejected = %d, want 2", got)
+	}
+}
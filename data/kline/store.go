@@ -0,0 +1,218 @@
+package kline
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps the most recent closed candles plus the forming candle
+// for every symbol/interval pair seen on the stream.
+type Store struct {
+	capacity int
+	// series: symbol -> interval -> *series
+	series map[string]map[string]*series
+	mu     sync.RWMutex
+}
+
+// series is a fixed-size ring buffer of closed candles.
+type series struct {
+	candles []Candle
+	head    int // index of the oldest candle
+	count   int
+	live    *Candle
+}
+
+func NewStore(capacity int) *Store {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &Store{
+		capacity: capacity,
+		series:   make(map[string]map[string]*series),
+	}
+}
+
+// Run records every event passing through and forwards it unchanged,
+// so the store can sit anywhere in the kline pipeline.
+func (s *Store) Run(inChan <-chan KlineEvent) <-chan KlineEvent {
+	outChan := make(chan KlineEvent, 100)
+
+	go func() {
+		defer close(outChan)
+		for event := range inChan {
+			s.Update(event.Candle)
+			outChan <- event
+		}
+	}()
+
+	return outChan
+}
+
+// Update stores a candle. Closed candles are appended to the history
+// (replacing the newest one if it has the same start time); open candles
+// replace the live candle.
+func (s *Store) Update(c Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[c.Symbol]; !ok {
+		s.series[c.Symbol] = make(map[string]*series)
+	}
+	ser, ok := s.series[c.Symbol][c.Interval]
+	if !ok {
+		ser = &series{candles: make([]Candle, s.capacity)}
+		s.series[c.Symbol][c.Interval] = ser
+	}
+
+	if !c.IsClosed {
+		// Ignore late ticks for a candle that has already closed
+		if ser.count > 0 && !ser.newest().StartTime.Before(c.StartTime) {
+			return
+		}
+		live := c
+		ser.live = &live
+		return
+	}
+
+	if ser.live != nil && !ser.live.StartTime.After(c.StartTime) {
+		ser.live = nil
+	}
+
+	if ser.count > 0 {
+		newest := ser.newest()
+		if newest.StartTime.Equal(c.StartTime) {
+			ser.candles[ser.index(ser.count-1)] = c
+			return
+		}
+		if newest.StartTime.After(c.StartTime) {
+			return
+		}
+	}
+
+	if ser.count < len(ser.candles) {
+		ser.candles[ser.index(ser.count)] = c
+		ser.count++
+		return
+	}
+	ser.candles[ser.head] = c
+	ser.head = (ser.head + 1) % len(ser.candles)
+}
+
+// Drop discards the candles of a pair no longer monitored.
+func (s *Store) Drop(symbol, interval string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.series[symbol], interval)
+	if len(s.series[symbol]) == 0 {
+		delete(s.series, symbol)
+	}
+}
+
+// Pair identifies a symbol/interval series.
+type Pair struct {
+	Symbol   string
+	Interval string
+}
+
+// Pairs returns every pair with stored candles.
+func (s *Store) Pairs() []Pair {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pairs []Pair
+	for symbol, intervals := range s.series {
+		for interval := range intervals {
+			pairs = append(pairs, Pair{Symbol: symbol, Interval: interval})
+		}
+	}
+	return pairs
+}
+
+// Snapshot returns a copy of the closed candles, oldest first.
+func (s *Store) Snapshot(symbol, interval string) []Candle {
+	return s.Last(symbol, interval, s.capacity)
+}
+
+// Last returns up to n of the most recent closed candles, oldest first.
+func (s *Store) Last(symbol, interval string, n int) []Candle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser := s.get(symbol, interval)
+	if ser == nil || n <= 0 {
+		return nil
+	}
+	if n > ser.count {
+		n = ser.count
+	}
+
+	out := make([]Candle, 0, n)
+	for i := ser.count - n; i < ser.count; i++ {
+		out = append(out, ser.candles[ser.index(i)])
+	}
+	return out
+}
+
+// Range returns the closed candles whose start time falls within [from, to], oldest first.
+func (s *Store) Range(symbol, interval string, from, to time.Time) []Candle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser := s.get(symbol, interval)
+	if ser == nil {
+		return nil
+	}
+
+	var out []Candle
+	for i := 0; i < ser.count; i++ {
+		c := ser.candles[ser.index(i)]
+		if c.StartTime.Before(from) {
+			continue
+		}
+		if c.StartTime.After(to) {
+			break
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// Live returns the currently forming candle, if any.
+func (s *Store) Live(symbol, interval string) (Candle, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser := s.get(symbol, interval)
+	if ser == nil || ser.live == nil {
+		return Candle{}, false
+	}
+	return *ser.live, true
+}
+
+// Len returns the number of closed candles held for the pair.
+func (s *Store) Len(symbol, interval string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser := s.get(symbol, interval)
+	if ser == nil {
+		return 0
+	}
+	return ser.count
+}
+
+func (s *Store) get(symbol, interval string) *series {
+	if intervals, ok := s.series[symbol]; ok {
+		return intervals[interval]
+	}
+	return nil
+}
+
+func (ser *series) index(i int) int {
+	return (ser.head + i) % len(ser.candles)
+}
+
+func (ser *series) newest() Candle {
+	return ser.candles[ser.index(ser.count-1)]
+}
@@ -0,0 +1,65 @@
+package kline
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// AggTradeEvent is a Binance aggregated trade stream payload.
+type AggTradeEvent struct {
+	Event        string `json:"e"`
+	Time         int64  `json:"E"`
+	Symbol       string `json:"s"`
+	AggTradeID   int64  `json:"a"`
+	Price        string `json:"p"`
+	Quantity     string `json:"q"`
+	FirstTradeID int64  `json:"f"`
+	LastTradeID  int64  `json:"l"`
+	TradeTime    int64  `json:"T"`
+	IsBuyerMaker bool   `json:"m"`
+
+	// Trade is the parsed and validated form of the payload, set by the Processor.
+	Trade Trade `json:"-"`
+}
+
+// Trade is an aggregated trade with numeric fields.
+type Trade struct {
+	Symbol       string
+	ID           int64
+	FirstTradeID int64
+	LastTradeID  int64
+	Price        float64
+	Quantity     float64
+	Time         time.Time
+	IsBuyerMaker bool
+}
+
+// ToTrade parses and validates the trade payload.
+func (e *AggTradeEvent) ToTrade() (Trade, error) {
+	price, err := strconv.ParseFloat(e.Price, 64)
+	if err != nil {
+		return Trade{}, fmt.Errorf("invalid price %q: %w", e.Price, err)
+	}
+	qty, err := strconv.ParseFloat(e.Quantity, 64)
+	if err != nil {
+		return Trade{}, fmt.Errorf("invalid quantity %q: %w", e.Quantity, err)
+	}
+	if e.Symbol == "" {
+		return Trade{}, fmt.Errorf("missing symbol")
+	}
+	if price <= 0 || qty <= 0 {
+		return Trade{}, fmt.Errorf("non-positive price %v or quantity %v", price, qty)
+	}
+
+	return Trade{
+		Symbol:       e.Symbol,
+		ID:           e.AggTradeID,
+		FirstTradeID: e.FirstTradeID,
+		LastTradeID:  e.LastTradeID,
+		Price:        price,
+		Quantity:     qty,
+		Time:         time.UnixMilli(e.TradeTime).UTC(),
+		IsBuyerMaker: e.IsBuyerMaker,
+	}, nil
+}
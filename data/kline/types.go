@@ -1,12 +1,13 @@
 package kline
 
-import "strconv"
-
 type KlineEvent struct {
 	Event  string `json:"e"`
 	Time   int64  `json:"E"`
 	Symbol string `json:"s"`
 	Kline  Kline  `json:"k"`
+
+	// Candle is the parsed and validated form of Kline, set by the Processor.
+	Candle Candle `json:"-"`
 }
 
 type Kline struct {
@@ -27,7 +28,3 @@ type Kline struct {
 	TakerBuyBase string `json:"V"`
 	TakerBuyQuote string `json:"Q"`
 }
-
-func (k *Kline) GetClosePrice() (float64, error) {
-	return strconv.ParseFloat(k.Close, 64)
-}
@@ -0,0 +1,150 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reader reads frames from recordings, and from files of raw frames, one per
+// line, which have no receive times. Files ending in .gz are decompressed.
+type Reader struct {
+	files   []string
+	file    *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+}
+
+// Open reads a single file, or every recording in a directory in order.
+func Open(path string) (*Reader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = Files(path); err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, errors.New("no recordings in " + path)
+		}
+	}
+	return &Reader{files: files}, nil
+}
+
+// Next returns the next frame, or io.EOF after the last one.
+func (r *Reader) Next() (Frame, error) {
+	for {
+		if r.scanner == nil {
+			if len(r.files) == 0 {
+				return Frame{}, io.EOF
+			}
+			if err := r.open(r.files[0]); err != nil {
+				return Frame{}, err
+			}
+			r.files = r.files[1:]
+		}
+		if r.scanner.Scan() {
+			line := r.scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+			return parseLine(line), nil
+		}
+		err := r.scanner.Err()
+		r.closeFile()
+		if err != nil {
+			return Frame{}, err
+		}
+	}
+}
+
+// parseLine accepts recorded frames and raw frames.
+func parseLine(line []byte) Frame {
+	var rec record
+	if err := json.Unmarshal(line, &rec); err == nil {
+		if len(rec.Frame) > 0 {
+			return Frame{ReceivedAt: rec.ReceivedAt, Data: rec.Frame}
+		}
+		if len(rec.Raw) > 0 {
+			return Frame{ReceivedAt: rec.ReceivedAt, Data: rec.Raw}
+		}
+	}
+	return Frame{Data: append([]byte(nil), line...)}
+}
+
+func (r *Reader) open(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	var in io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		r.gz = gz
+		in = gz
+	}
+	r.file = f
+	r.scanner = bufio.NewScanner(in)
+	r.scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	return nil
+}
+
+func (r *Reader) closeFile() {
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	if r.file != nil {
+		r.file.Close()
+	}
+	r.file, r.gz, r.scanner = nil, nil, nil
+}
+
+// Close releases the open file.
+func (r *Reader) Close() error {
+	r.closeFile()
+	r.files = nil
+	return nil
+}
+
+// Play sends the frames of r in order. With a speed of 0 frames are sent as
+// fast as they are consumed; otherwise the gaps between receive times are
+// kept, divided by speed, so 1 replays in real time. The channel is closed
+// after the last frame or on a read error.
+func Play(r *Reader, speed float64, logger *zap.Logger) <-chan []byte {
+	out := make(chan []byte, 100)
+	go func() {
+		defer close(out)
+		var prev time.Time
+		for {
+			f, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				logger.Error("Failed to read recording", zap.Error(err))
+				return
+			}
+			if speed > 0 && !f.ReceivedAt.IsZero() {
+				if !prev.IsZero() && f.ReceivedAt.After(prev) {
+					time.Sleep(time.Duration(float64(f.ReceivedAt.Sub(prev)) / speed))
+				}
+				prev = f.ReceivedAt
+			}
+			out <- f.Data
+		}
+	}()
+	return out
+}
@@ -0,0 +1,221 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fibo-monitor/config"
+
+	"go.uber.org/zap"
+)
+
+// Frame is a raw combined-stream frame with the time it was received.
+type Frame struct {
+	ReceivedAt time.Time
+	Data       []byte
+}
+
+// record is a Frame as stored. Frames that are valid JSON are embedded as
+// is; anything else, such as a truncated frame, is kept base64 encoded in Raw
+// so the recording reproduces the stream byte for byte.
+type record struct {
+	ReceivedAt time.Time       `json:"received_at"`
+	Frame      json.RawMessage `json:"frame,omitempty"`
+	Raw        []byte          `json:"raw,omitempty"`
+}
+
+const (
+	filePrefix = "frames-"
+	fileSuffix = ".jsonl.gz"
+	// queueSize frames may wait for the writer before frames are dropped
+	queueSize = 10000
+)
+
+// Recorder writes the frames passing through it to gzip-compressed files of
+// one JSON Frame per line, starting a new file every RotateInterval or after
+// MaxSizeMB of uncompressed frames.
+type Recorder struct {
+	config  config.RecordingConfig
+	frames  chan Frame
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+	logger  *zap.Logger
+
+	file    *os.File
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	opened  time.Time
+	written int64
+}
+
+func NewRecorder(cfg config.RecordingConfig, logger *zap.Logger) (*Recorder, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		config: cfg,
+		frames: make(chan Frame, queueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+	go r.writeLoop()
+	return r, nil
+}
+
+// Run passes frames through unchanged and records them. Recording never
+// blocks the stream: frames are dropped while the writer is behind.
+func (r *Recorder) Run(in <-chan []byte) <-chan []byte {
+	out := make(chan []byte, 100)
+	go func() {
+		defer close(out)
+		for msg := range in {
+			select {
+			case r.frames <- Frame{ReceivedAt: time.Now(), Data: msg}:
+			default:
+				if r.dropped.Add(1)%1000 == 1 {
+					r.logger.Warn("Recorder behind, frames dropped", zap.Int64("dropped", r.dropped.Load()))
+				}
+			}
+			out <- msg
+		}
+	}()
+	return out
+}
+
+// Dropped returns the number of frames not recorded.
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close writes the queued frames and closes the current file.
+func (r *Recorder) Close() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+func (r *Recorder) writeLoop() {
+	defer close(r.done)
+	defer r.closeFile()
+
+	for {
+		select {
+		case f := <-r.frames:
+			r.write(f)
+		case <-r.stop:
+			for {
+				select {
+				case f := <-r.frames:
+					r.write(f)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) write(f Frame) {
+	if r.file != nil && r.due(f.ReceivedAt) {
+		r.closeFile()
+	}
+	if r.file == nil {
+		if err := r.openFile(f.ReceivedAt); err != nil {
+			r.logger.Error("Failed to open recording file", zap.Error(err))
+			return
+		}
+	}
+	rec := record{ReceivedAt: f.ReceivedAt, Raw: f.Data}
+	if json.Valid(f.Data) {
+		rec = record{ReceivedAt: f.ReceivedAt, Frame: f.Data}
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		r.logger.Error("Frame not recorded", zap.Error(err))
+		return
+	}
+	line = append(line, '\n')
+	if _, err := r.buf.Write(line); err != nil {
+		r.logger.Error("Failed to write recording", zap.Error(err))
+		return
+	}
+	r.written += int64(len(line))
+}
+
+// due reports whether the current file should be rotated.
+func (r *Recorder) due(now time.Time) bool {
+	if r.config.RotateInterval > 0 && now.Sub(r.opened) >= r.config.RotateInterval {
+		return true
+	}
+	return r.config.MaxSizeMB > 0 && r.written >= int64(r.config.MaxSizeMB)<<20
+}
+
+func (r *Recorder) openFile(now time.Time) error {
+	name := filepath.Join(r.config.Dir, filePrefix+now.UTC().Format("20060102T150405.000Z")+fileSuffix)
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.gz = gzip.NewWriter(f)
+	r.buf = bufio.NewWriterSize(r.gz, 64*1024)
+	r.opened = now
+	r.written = 0
+	r.logger.Info("Recording frames", zap.String("file", name))
+	r.prune()
+	return nil
+}
+
+func (r *Recorder) closeFile() {
+	if r.file == nil {
+		return
+	}
+	if err := r.buf.Flush(); err != nil {
+		r.logger.Error("Failed to flush recording", zap.Error(err))
+	}
+	if err := r.gz.Close(); err != nil {
+		r.logger.Error("Failed to finish recording", zap.Error(err))
+	}
+	if err := r.file.Close(); err != nil {
+		r.logger.Error("Failed to close recording", zap.Error(err))
+	}
+	r.file, r.gz, r.buf = nil, nil, nil
+}
+
+// prune removes the oldest recordings beyond MaxFiles.
+func (r *Recorder) prune() {
+	if r.config.MaxFiles <= 0 {
+		return
+	}
+	files, err := Files(r.config.Dir)
+	if err != nil {
+		r.logger.Warn("Failed to list recordings", zap.Error(err))
+		return
+	}
+	for len(files) > r.config.MaxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			r.logger.Warn("Failed to remove recording", zap.String("file", files[0]), zap.Error(err))
+		}
+		files = files[1:]
+	}
+}
+
+// Files returns the recordings in dir, oldest first.
+func Files(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	// Names embed the UTC start time, so they sort chronologically
+	sort.Strings(files)
+	return files, nil
+}
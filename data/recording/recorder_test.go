@@ -0,0 +1,51 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"fibo-monitor/config"
+
+	"go.uber.org/zap"
+)
+
+func TestRecordingIsFaithful(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(config.RecordingConfig{Dir: dir}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := [][]byte{
+		[]byte(`{"stream":"btcusdt@kline_1m","data":{"e":"kline"}}`),
+		[]byte(`{"stream":"btcusdt@kline_1m","data":{"e":"kl`),
+		{0xff, '\n', 0x00},
+		[]byte(`{"stream":"ethusdt@kline_1m","data":{"e":"kline"}}`),
+	}
+	in := make(chan []byte, len(frames))
+	for _, f := range frames {
+		in <- f
+	}
+	close(in)
+	for range r.Run(in) {
+	}
+	r.Close()
+
+	reader, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	for i, want := range frames {
+		f, err := reader.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !bytes.Equal(f.Data, want) || f.ReceivedAt.IsZero() {
+			t.Fatalf("frame %d = %q at %v, want %q", i, f.Data, f.ReceivedAt, want)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("after the last frame: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package binancetest
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"fibo-monitor/data/kline"
+)
+
+// Bar is one scripted kline.
+type Bar struct {
+	Open, High, Low, Close, Volume float64
+}
+
+// Series scripts closed klines of one pair, starting at Start.
+type Series struct {
+	Symbol   string // lowercase, as in stream names
+	Interval string
+	Start    time.Time
+	Bars     []Bar
+}
+
+// Stream is the stream name the klines are published on.
+func (s Series) Stream() string {
+	return strings.ToLower(s.Symbol) + "@kline_" + s.Interval
+}
+
+// Payloads returns the kline events of the series as Binance encodes them.
+func (s Series) Payloads() ([][]byte, error) {
+	size, err := kline.ParseInterval(s.Interval)
+	if err != nil {
+		return nil, err
+	}
+	symbol := strings.ToUpper(s.Symbol)
+	payloads := make([][]byte, 0, len(s.Bars))
+	for i, b := range s.Bars {
+		start := s.Start.Add(time.Duration(i) * size)
+		closeTime := start.Add(size).UnixMilli() - 1
+		payloads = append(payloads, mustJSON(kline.KlineEvent{
+			Event:  "kline",
+			Time:   closeTime + 1,
+			Symbol: symbol,
+			Kline: kline.Kline{
+				StartTime:   start.UnixMilli(),
+				CloseTime:   closeTime,
+				Symbol:      symbol,
+				Interval:    s.Interval,
+				Open:        price(b.Open),
+				High:        price(b.High),
+				Low:         price(b.Low),
+				Close:       price(b.Close),
+				Volume:      price(b.Volume),
+				QuoteVolume: price(b.Volume * b.Close),
+				Trades:      1,
+				IsClosed:    true,
+			},
+		}))
+	}
+	return payloads, nil
+}
+
+// Closes builds bars from closing prices: each bar opens at the previous
+// close and spans both prices.
+func Closes(closes []float64, volume float64) []Bar {
+	bars := make([]Bar, len(closes))
+	for i, c := range closes {
+		open := c
+		if i > 0 {
+			open = closes[i-1]
+		}
+		bars[i] = Bar{Open: open, High: math.Max(open, c), Low: math.Min(open, c), Close: c, Volume: volume}
+	}
+	return bars
+}
+
+// Wave returns n closing prices oscillating around base by amplitude with the
+// given period in bars, which makes the EMAs cross regularly.
+func Wave(n int, base, amplitude float64, period int) []float64 {
+	closes := make([]float64, n)
+	for i := range closes {
+		closes[i] = base + amplitude*math.Sin(2*math.Pi*float64(i)/float64(period))
+	}
+	return closes
+}
+
+// Play publishes the payloads on stream, one every interval, until all are
+// sent or ctx is done.
+func (s *Server) Play(ctx context.Context, stream string, payloads [][]byte, interval time.Duration) {
+	for i, p := range payloads {
+		if i > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+		s.Publish(stream, p)
+	}
+}
+
+func price(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
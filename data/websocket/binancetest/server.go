@@ -0,0 +1,401 @@
+// Package binancetest imitates the Binance futures combined-stream endpoint,
+// for running websocket.Client and the pipeline without network access.
+package binancetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// sendQueue frames may wait for a slow client; Binance drops clients that
+// fall further behind, and so does the server.
+const sendQueue = 1000
+
+// Server speaks the combined-stream protocol: /stream?streams=a/b wraps each
+// payload as {"stream":...,"data":...}, /ws/<stream> sends raw payloads.
+// SUBSCRIBE, UNSUBSCRIBE and LIST_SUBSCRIPTIONS requests are answered like
+// Binance does.
+type Server struct {
+	// PingInterval is how often connections are pinged, 0 disables pings
+	PingInterval time.Duration
+
+	listener net.Listener
+	server   *http.Server
+	upgrader websocket.Upgrader
+	logger   *zap.Logger
+
+	mu         sync.Mutex
+	conns      map[*conn]bool
+	stallUntil time.Time
+	refuse     bool
+
+	dropFrames atomic.Int64
+	sent       atomic.Int64
+	pongs      atomic.Int64
+	accepted   atomic.Int64
+}
+
+type conn struct {
+	ws       *websocket.Conn
+	combined bool
+	send     chan []byte
+	closed   chan struct{}
+	once     sync.Once
+
+	mu      sync.Mutex
+	streams map[string]bool
+}
+
+func NewServer(logger *zap.Logger) *Server {
+	return &Server{
+		PingInterval: 3 * time.Minute,
+		conns:        make(map[*conn]bool),
+		logger:       logger,
+	}
+}
+
+// Start listens on addr, e.g. 127.0.0.1:0 for any free port.
+func (s *Server) Start(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", s.handle)
+	mux.HandleFunc("/ws", s.handle)
+	mux.HandleFunc("/ws/", s.handle)
+	s.server = &http.Server{Handler: mux}
+	go func() {
+		if err := s.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Mock server failed", zap.Error(err))
+		}
+	}()
+	s.logger.Info("Mock Binance stream server listening", zap.String("url", s.URL()))
+	return nil
+}
+
+// URL is the base URL to configure as binance.websocket_url.
+func (s *Server) URL() string {
+	return "ws://" + s.listener.Addr().String() + "/ws"
+}
+
+// Close drops all connections and stops listening.
+func (s *Server) Close() error {
+	s.Drop()
+	return s.server.Close()
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	refuse := s.refuse
+	s.mu.Unlock()
+	if refuse {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &conn{
+		ws:      ws,
+		send:    make(chan []byte, sendQueue),
+		closed:  make(chan struct{}),
+		streams: make(map[string]bool),
+	}
+	if r.URL.Path == "/stream" {
+		c.combined = true
+		for _, stream := range strings.Split(r.URL.Query().Get("streams"), "/") {
+			if stream != "" {
+				c.streams[stream] = true
+			}
+		}
+	} else if stream := strings.TrimPrefix(r.URL.Path, "/ws/"); stream != r.URL.Path && stream != "" {
+		c.streams[stream] = true
+	}
+	ws.SetPongHandler(func(string) error {
+		s.pongs.Add(1)
+		return nil
+	})
+
+	s.mu.Lock()
+	s.conns[c] = true
+	s.mu.Unlock()
+	s.accepted.Add(1)
+	s.logger.Info("Client connected", zap.Strings("streams", c.subscriptions()))
+
+	go s.writeLoop(c)
+	s.readLoop(c)
+}
+
+// readLoop answers method calls until the connection ends.
+func (s *Server) readLoop(c *conn) {
+	defer s.remove(c)
+	for {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req struct {
+			Method string   `json:"method"`
+			Params []string `json:"params"`
+			ID     *int64   `json:"id"`
+		}
+		if err := json.Unmarshal(msg, &req); err != nil || req.ID == nil {
+			c.queue(mustJSON(map[string]interface{}{
+				"error": map[string]interface{}{"code": 3, "msg": "Invalid JSON"},
+			}))
+			continue
+		}
+		reply := map[string]interface{}{"result": nil, "id": *req.ID}
+		switch req.Method {
+		case "SUBSCRIBE":
+			c.mu.Lock()
+			for _, stream := range req.Params {
+				c.streams[stream] = true
+			}
+			c.mu.Unlock()
+		case "UNSUBSCRIBE":
+			c.mu.Lock()
+			for _, stream := range req.Params {
+				delete(c.streams, stream)
+			}
+			c.mu.Unlock()
+		case "LIST_SUBSCRIPTIONS":
+			reply["result"] = c.subscriptions()
+		default:
+			delete(reply, "result")
+			reply["error"] = map[string]interface{}{"code": 2, "msg": "Invalid request: unknown method " + req.Method}
+		}
+		c.queue(mustJSON(reply))
+	}
+}
+
+func (s *Server) writeLoop(c *conn) {
+	defer s.remove(c)
+	var ping <-chan time.Time
+	if s.PingInterval > 0 {
+		ticker := time.NewTicker(s.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ping:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case msg := <-c.send:
+			s.waitStall(c)
+			if err := c.ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// waitStall holds frames while the server is stalled.
+func (s *Server) waitStall(c *conn) {
+	for {
+		s.mu.Lock()
+		wait := time.Until(s.stallUntil)
+		s.mu.Unlock()
+		if wait <= 0 {
+			return
+		}
+		select {
+		case <-time.After(wait):
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (s *Server) remove(c *conn) {
+	c.once.Do(func() {
+		close(c.closed)
+		c.ws.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		s.logger.Info("Client disconnected")
+	})
+}
+
+func (c *conn) subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	streams := make([]string, 0, len(c.streams))
+	for stream := range c.streams {
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+func (c *conn) subscribed(stream string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.streams[stream]
+}
+
+// queue reports false when the client is too slow and has been dropped.
+func (c *conn) queue(msg []byte) bool {
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) connections() []*conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// WaitConnected blocks until a client is connected or ctx is done.
+func (s *Server) WaitConnected(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for len(s.connections()) == 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Publish sends a payload to every connection subscribed to stream, wrapped
+// as a combined-stream frame where the connection uses /stream.
+func (s *Server) Publish(stream string, payload []byte) {
+	for n := s.dropFrames.Load(); n > 0; n = s.dropFrames.Load() {
+		if s.dropFrames.CompareAndSwap(n, n-1) {
+			return
+		}
+	}
+	frame := mustJSON(map[string]interface{}{"stream": stream, "data": json.RawMessage(payload)})
+	for _, c := range s.connections() {
+		if !c.subscribed(stream) {
+			continue
+		}
+		msg := payload
+		if c.combined {
+			msg = frame
+		}
+		if !c.queue(msg) {
+			s.logger.Warn("Client too slow, dropped")
+			s.remove(c)
+			continue
+		}
+		s.sent.Add(1)
+	}
+}
+
+// PublishFrame publishes a recorded combined-stream frame to its stream.
+func (s *Server) PublishFrame(frame []byte) error {
+	var f struct {
+		Stream string          `json:"stream"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(frame, &f); err != nil {
+		return err
+	}
+	if f.Stream == "" || len(f.Data) == 0 {
+		return errors.New("not a combined-stream frame")
+	}
+	s.Publish(f.Stream, f.Data)
+	return nil
+}
+
+// SendRaw sends data as is to every connection, e.g. malformed JSON.
+func (s *Server) SendRaw(data []byte) {
+	for _, c := range s.connections() {
+		c.queue(data)
+	}
+}
+
+// Stall holds all frames for d, as during a network stall; held frames are
+// delivered afterwards.
+func (s *Server) Stall(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stallUntil = time.Now().Add(d)
+}
+
+// DropFrames silently discards the next n published frames.
+func (s *Server) DropFrames(n int) {
+	s.dropFrames.Add(int64(n))
+}
+
+// Disconnect closes every connection with a close frame, like Binance does
+// after 24 hours.
+func (s *Server) Disconnect() {
+	for _, c := range s.connections() {
+		c.ws.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "mock disconnect"), time.Now().Add(time.Second))
+		s.remove(c)
+	}
+}
+
+// Drop closes every connection without a close frame, like a lost network.
+func (s *Server) Drop() {
+	for _, c := range s.connections() {
+		c.ws.UnderlyingConn().Close()
+		s.remove(c)
+	}
+}
+
+// Refuse makes new connections fail with 503 until called with false.
+func (s *Server) Refuse(refuse bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refuse = refuse
+}
+
+// Stats counts what the server has done so far.
+type Stats struct {
+	Connections int   `json:"connections"` // open now
+	Accepted    int64 `json:"accepted"`
+	Sent        int64 `json:"sent"`
+	Pongs       int64 `json:"pongs"`
+}
+
+func (s *Server) Stats() Stats {
+	return Stats{
+		Connections: len(s.connections()),
+		Accepted:    s.accepted.Load(),
+		Sent:        s.sent.Load(),
+		Pongs:       s.pongs.Load(),
+	}
+}
+
+func mustJSON(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"fibo-monitor/redact"
+
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
@@ -21,6 +23,7 @@ type Client struct {
 	mu                sync.Mutex
 	isConnected       bool
 	streams           []string
+	requestID         int
 }
 
 func NewClient(url string, reconnectInterval, pingInterval time.Duration, logger *zap.Logger) *Client {
@@ -55,7 +58,7 @@ func (c *Client) connectInternal() error {
 	streamParams := strings.Join(c.streams, "/")
 	fullURL := fmt.Sprintf("%s?streams=%s", baseURL, streamParams)
 
-	c.logger.Info("Connecting to WebSocket", zap.String("url", fullURL))
+	c.logger.Info("Connecting to WebSocket", zap.String("url", redact.URL(baseURL)), zap.Int("streams", len(c.streams)))
 
 	conn, _, err := websocket.DefaultDialer.Dial(fullURL, nil)
 	if err != nil {
@@ -123,6 +126,58 @@ func (c *Client) reconnect() {
 	}
 }
 
+// Subscribe adds streams to the live connection. The streams are also used
+// for future reconnects.
+func (c *Client) Subscribe(streams []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range streams {
+		if !containsStream(c.streams, s) {
+			c.streams = append(c.streams, s)
+		}
+	}
+	return c.sendRequest("SUBSCRIBE", streams)
+}
+
+// Unsubscribe removes streams from the live connection and future reconnects.
+func (c *Client) Unsubscribe(streams []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.streams[:0]
+	for _, s := range c.streams {
+		if !containsStream(streams, s) {
+			kept = append(kept, s)
+		}
+	}
+	c.streams = kept
+	return c.sendRequest("UNSUBSCRIBE", streams)
+}
+
+// sendRequest sends a combined-stream method call. Callers must hold c.mu.
+// Without a connection there is nothing to do, the next connect uses c.streams.
+func (c *Client) sendRequest(method string, streams []string) error {
+	if !c.isConnected || len(streams) == 0 {
+		return nil
+	}
+	c.requestID++
+	return c.conn.WriteJSON(map[string]interface{}{
+		"method": method,
+		"params": streams,
+		"id":     c.requestID,
+	})
+}
+
+func containsStream(streams []string, s string) bool {
+	for _, stream := range streams {
+		if stream == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Client) Messages() <-chan []byte {
 	return c.msgChan
 }
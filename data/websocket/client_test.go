@@ -0,0 +1,141 @@
+package websocket_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"fibo-monitor/data/websocket"
+	"fibo-monitor/data/websocket/binancetest"
+
+	"go.uber.org/zap"
+)
+
+const (
+	btcStream = "btcusdt@kline_1m"
+	ethStream = "ethusdt@kline_1m"
+)
+
+func startServer(t *testing.T) *binancetest.Server {
+	t.Helper()
+	server := binancetest.NewServer(zap.NewNop())
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func connect(t *testing.T, server *binancetest.Server, streams ...string) *websocket.Client {
+	t.Helper()
+	client := websocket.NewClient(server.URL(), 20*time.Millisecond, 0, zap.NewNop())
+	if err := client.Connect(streams); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	waitConnections(t, server, 1)
+	return client
+}
+
+func waitConnections(t *testing.T, server *binancetest.Server, accepted int64) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		if st := server.Stats(); st.Accepted >= accepted && st.Connections > 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("no connection %d: %+v", accepted, server.Stats())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// next returns the next stream frame, skipping method replies.
+func next(t *testing.T, client *websocket.Client) (stream string, data string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-client.Messages():
+			var frame struct {
+				Stream string          `json:"stream"`
+				Data   json.RawMessage `json:"data"`
+			}
+			if json.Unmarshal(msg, &frame) == nil && frame.Stream != "" {
+				return frame.Stream, string(frame.Data)
+			}
+		case <-timeout:
+			t.Fatal("no frame received")
+		}
+	}
+}
+
+func TestClientReconnects(t *testing.T) {
+	faults := map[string]func(*binancetest.Server){
+		"drop":       (*binancetest.Server).Drop,
+		"disconnect": (*binancetest.Server).Disconnect,
+	}
+	for name, fault := range faults {
+		t.Run(name, func(t *testing.T) {
+			server := startServer(t)
+			client := connect(t, server, btcStream)
+
+			server.Publish(btcStream, []byte(`{"n":1}`))
+			if _, data := next(t, client); data != `{"n":1}` {
+				t.Fatalf("data = %s", data)
+			}
+
+			fault(server)
+			waitConnections(t, server, 2)
+
+			server.Publish(btcStream, []byte(`{"n":2}`))
+			if _, data := next(t, client); data != `{"n":2}` {
+				t.Fatalf("data after reconnect = %s", data)
+			}
+		})
+	}
+}
+
+func TestClientSubscribeUnsubscribe(t *testing.T) {
+	server := startServer(t)
+	client := connect(t, server, btcStream)
+
+	if err := client.Subscribe([]string{ethStream}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Unsubscribe([]string{btcStream}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests are answered in order; once both are, only eth is published
+	deadline := time.After(5 * time.Second)
+	for replies := 0; replies < 2; {
+		select {
+		case msg := <-client.Messages():
+			if strings.Contains(string(msg), `"id"`) {
+				replies++
+			}
+		case <-deadline:
+			t.Fatal("no replies to SUBSCRIBE/UNSUBSCRIBE")
+		}
+	}
+	server.Publish(btcStream, []byte(`{"n":1}`))
+	server.Publish(ethStream, []byte(`{"n":2}`))
+	if stream, _ := next(t, client); stream != ethStream {
+		t.Fatalf("stream = %s, want %s", stream, ethStream)
+	}
+
+	// Reconnects use the updated streams
+	server.Drop()
+	waitConnections(t, server, 2)
+	server.Publish(btcStream, []byte(`{"n":3}`))
+	server.Publish(ethStream, []byte(`{"n":4}`))
+	if stream, data := next(t, client); stream != ethStream || data != `{"n":4}` {
+		t.Fatalf("after reconnect got %s %s", stream, data)
+	}
+}
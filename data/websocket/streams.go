@@ -0,0 +1,13 @@
+package websocket
+
+import "fmt"
+
+// KlineStream returns the stream name for a symbol's klines of the given interval.
+func KlineStream(symbol, interval string) string {
+	return fmt.Sprintf("%s@kline_%s", symbol, interval)
+}
+
+// AggTradeStream returns the stream name for a symbol's aggregated trades.
+func AggTradeStream(symbol string) string {
+	return fmt.Sprintf("%s@aggTrade", symbol)
+}
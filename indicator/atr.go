@@ -0,0 +1,61 @@
+package indicator
+
+import "math"
+
+// ATR is the Average True Range with Wilder smoothing.
+// The first Period true ranges seed the average with a simple mean.
+type ATR struct {
+	Period int
+	state  atrState
+}
+
+type atrState struct {
+	prevClose   float64
+	avg         float64
+	samples     int
+	initialized bool
+}
+
+func NewATR(period int) *ATR {
+	return &ATR{Period: period}
+}
+
+// Ready reports whether enough candles have been committed for a seeded value.
+func (a *ATR) Ready() bool {
+	return a.state.samples >= a.Period
+}
+
+// Value returns the ATR of the committed candles.
+func (a *ATR) Value() float64 {
+	return a.state.avg
+}
+
+// UpdateAndCommit adds a closed candle and returns the new ATR
+func (a *ATR) UpdateAndCommit(high, low, close float64) float64 {
+	a.state = a.state.next(high, low, close, a.Period)
+	return a.state.avg
+}
+
+// Calculate returns the ATR for a given candle without updating the state
+func (a *ATR) Calculate(high, low, close float64) float64 {
+	next := a.state.next(high, low, close, a.Period)
+	return next.avg
+}
+
+func (s atrState) next(high, low, close float64, period int) atrState {
+	tr := high - low
+	if s.initialized {
+		tr = math.Max(tr, math.Max(math.Abs(high-s.prevClose), math.Abs(low-s.prevClose)))
+	}
+
+	s.samples++
+	if s.samples <= period {
+		s.avg += (tr - s.avg) / float64(s.samples)
+	} else {
+		n := float64(period)
+		s.avg = (s.avg*(n-1) + tr) / n
+	}
+	s.prevClose = close
+	s.initialized = true
+	return s
+}
@@ -0,0 +1,101 @@
+package indicator
+
+import "math"
+
+// BollingerValue is one Bollinger Bands reading.
+type BollingerValue struct {
+	Middle    float64
+	Upper     float64
+	Lower     float64
+	PercentB  float64 // position of price within the bands, 0 = lower, 1 = upper
+	Bandwidth float64 // (Upper - Lower) / Middle
+}
+
+// Bollinger computes Bollinger Bands over a rolling window of closes,
+// using the population standard deviation. It is not used by the pipeline
+// yet.
+type Bollinger struct {
+	Period     int
+	Multiplier float64
+	window     []float64 // committed closes, ring buffer
+	head       int
+	count      int
+	Value      BollingerValue
+}
+
+func NewBollinger(period int, multiplier float64) *Bollinger {
+	return &Bollinger{
+		Period:     period,
+		Multiplier: multiplier,
+		window:     make([]float64, period),
+	}
+}
+
+// Ready reports whether the window is full.
+func (b *Bollinger) Ready() bool {
+	return b.count >= b.Period
+}
+
+// UpdateAndCommit adds a closed candle's price and returns the new reading
+func (b *Bollinger) UpdateAndCommit(price float64) BollingerValue {
+	if b.count < b.Period {
+		b.window[(b.head+b.count)%b.Period] = price
+		b.count++
+	} else {
+		b.window[b.head] = price
+		b.head = (b.head + 1) % b.Period
+	}
+	b.Value = b.compute(nil, price)
+	return b.Value
+}
+
+// Calculate returns the reading for a given price without updating the state.
+// The price replaces the oldest committed close once the window is full.
+func (b *Bollinger) Calculate(price float64) BollingerValue {
+	return b.compute(&price, price)
+}
+
+// compute evaluates the window, optionally with a pending close appended.
+func (b *Bollinger) compute(pending *float64, price float64) BollingerValue {
+	values := make([]float64, 0, b.Period)
+	skip := 0
+	if pending != nil && b.count == b.Period {
+		skip = 1
+	}
+	for i := skip; i < b.count; i++ {
+		values = append(values, b.window[(b.head+i)%b.Period])
+	}
+	if pending != nil {
+		values = append(values, *pending)
+	}
+	if len(values) == 0 {
+		return BollingerValue{}
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(variance / float64(len(values)))
+
+	out := BollingerValue{
+		Middle: mean,
+		Upper:  mean + b.Multiplier*std,
+		Lower:  mean - b.Multiplier*std,
+	}
+	if width := out.Upper - out.Lower; width != 0 {
+		out.PercentB = (price - out.Lower) / width
+	} else {
+		out.PercentB = 0.5
+	}
+	if mean != 0 {
+		out.Bandwidth = (out.Upper - out.Lower) / mean
+	}
+	return out
+}
@@ -1,5 +1,7 @@
 package indicator
 
+import "github.com/shopspring/decimal"
+
 type CrossType int
 
 const (
@@ -11,21 +13,42 @@ const (
 // CheckCrossover determines if a crossover happened given previous and current EMA values.
 // This is a stateless check. The caller maintains state.
 func CheckCrossover(prevShort, prevLong, currShort, currLong, price float64) CrossType {
+	return crossover(compare(prevShort, prevLong), compare(currShort, currLong), compare(price, currLong))
+}
+
+// CheckCrossoverDecimal is CheckCrossover for fixed-point decimal values.
+func CheckCrossoverDecimal(prevShort, prevLong, currShort, currLong, price decimal.Decimal) CrossType {
+	return crossover(prevShort.Cmp(prevLong), currShort.Cmp(currLong), price.Cmp(currLong))
+}
+
+// crossover works on the sign of (short - long) before and after the tick
+// and of (price - long) after the tick.
+func crossover(prev, curr, priceVsLong int) CrossType {
 	// Golden Cross: Short goes from below Long to above Long
-	if prevShort < prevLong && currShort > currLong {
+	if prev < 0 && curr > 0 {
 		// Filter: Price must be above Long EMA
-		if price > currLong {
+		if priceVsLong > 0 {
 			return GoldenCross
 		}
 	}
 
 	// Death Cross: Short goes from above Long to below Long
-	if prevShort > prevLong && currShort < currLong {
+	if prev > 0 && curr < 0 {
 		// Filter: Price must be below Long EMA
-		if price < currLong {
+		if priceVsLong < 0 {
 			return DeathCross
 		}
 	}
 
 	return None
 }
+
+func compare(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
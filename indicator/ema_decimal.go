@@ -0,0 +1,63 @@
+package indicator
+
+import "github.com/shopspring/decimal"
+
+// Arithmetic selects the number representation used for indicator math.
+type Arithmetic string
+
+const (
+	ArithmeticFloat   Arithmetic = "float"
+	ArithmeticDecimal Arithmetic = "decimal"
+)
+
+// decimalPlaces bounds the precision kept between updates so values don't
+// grow without limit. It is above the 8 decimals exchange prices carry, so
+// rounding stays far below a price tick.
+const decimalPlaces = 16
+
+// DecimalEMA is EMA computed in fixed-point decimal.
+type DecimalEMA struct {
+	Period      int
+	Value       decimal.Decimal
+	k           decimal.Decimal
+	oneMinusK   decimal.Decimal
+	initialized bool
+}
+
+func NewDecimalEMA(period int) *DecimalEMA {
+	k := decimal.NewFromInt(2).DivRound(decimal.NewFromInt(int64(period+1)), decimalPlaces)
+	return &DecimalEMA{
+		Period:    period,
+		k:         k,
+		oneMinusK: decimal.NewFromInt(1).Sub(k),
+	}
+}
+
+func (e *DecimalEMA) Update(price decimal.Decimal) decimal.Decimal {
+	if !e.initialized {
+		e.Value = price
+		e.initialized = true
+		return e.Value
+	}
+	return e.next(price)
+}
+
+// UpdateAndCommit updates the EMA and stores the new value (for closed candles)
+func (e *DecimalEMA) UpdateAndCommit(price decimal.Decimal) decimal.Decimal {
+	newValue := e.Update(price)
+	e.Value = newValue
+	return newValue
+}
+
+// Calculate returns the EMA value for a given price without updating the state
+func (e *DecimalEMA) Calculate(price decimal.Decimal) decimal.Decimal {
+	if !e.initialized {
+		return price
+	}
+	return e.next(price)
+}
+
+func (e *DecimalEMA) next(price decimal.Decimal) decimal.Decimal {
+	// EMA = Price * k + PrevEMA * (1-k)
+	return price.Mul(e.k).Add(e.Value.Mul(e.oneMinusK)).Round(decimalPlaces)
+}
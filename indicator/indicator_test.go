@@ -0,0 +1,127 @@
+package indicator
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// The RSI(14) example from StockCharts' ChartSchool, whose first value uses
+// simple averages of the first 14 changes and later ones Wilder smoothing.
+// The published table rounds the averages to two decimals, which moves its
+// values by up to 0.07 from the exact ones.
+var rsiCloses = []float64{
+	44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+	45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64,
+	46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18, 44.22, 44.57,
+	43.42, 42.66, 43.13,
+}
+
+var rsiValues = []float64{
+	70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+	54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+}
+
+func TestRSIReference(t *testing.T) {
+	rsi := NewRSI(14)
+	for i, price := range rsiCloses {
+		preview := rsi.Calculate(price)
+		got := rsi.UpdateAndCommit(price)
+		if preview != got {
+			t.Fatalf("close %d: Calculate %v, UpdateAndCommit %v", i, preview, got)
+		}
+		if i < 14 {
+			if rsi.Ready() {
+				t.Fatalf("close %d: ready before 14 changes", i)
+			}
+			continue
+		}
+		if want := rsiValues[i-14]; !near(got, want, 0.1) {
+			t.Errorf("close %d: RSI %.4f, want %.2f", i, got, want)
+		}
+	}
+}
+
+func TestRSIEdgeCases(t *testing.T) {
+	tests := []struct {
+		name   string
+		closes []float64
+		want   float64
+	}{
+		{"no data", nil, 50},
+		{"flat", []float64{10, 10, 10, 10}, 50},
+		{"only gains", []float64{1, 2, 3, 4}, 100},
+		{"only losses", []float64{4, 3, 2, 1}, 0},
+	}
+	for _, tt := range tests {
+		rsi := NewRSI(3)
+		for _, c := range tt.closes {
+			rsi.UpdateAndCommit(c)
+		}
+		if got := rsi.Value(); got != tt.want {
+			t.Errorf("%s: RSI %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMACDReference(t *testing.T) {
+	tests := []struct {
+		name  string
+		price func(i int) float64
+		want  MACDValue
+	}{
+		// All EMAs settle on the price
+		{"constant", func(int) float64 { return 100 }, MACDValue{}},
+		// An EMA of period n lags a ramp of slope b by b(n-1)/2, so the line
+		// settles at b((26-1) - (12-1))/2 = 7b and the signal follows it
+		{"ramp", func(i int) float64 { return 100 + float64(i) }, MACDValue{MACD: 7, Signal: 7}},
+		{"falling ramp", func(i int) float64 { return 1000 - 2*float64(i) }, MACDValue{MACD: -14, Signal: -14}},
+	}
+	for _, tt := range tests {
+		macd := NewMACD(12, 26, 9)
+		var got MACDValue
+		for i := 0; i < 1000; i++ {
+			preview := macd.Calculate(tt.price(i))
+			got = macd.UpdateAndCommit(tt.price(i))
+			if preview != got {
+				t.Fatalf("%s: Calculate %+v, UpdateAndCommit %+v", tt.name, preview, got)
+			}
+		}
+		if !near(got.MACD, tt.want.MACD, 1e-9) || !near(got.Signal, tt.want.Signal, 1e-9) || !near(got.Histogram, 0, 1e-9) {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBollingerReference(t *testing.T) {
+	// The population standard deviation example from Wikipedia: mean 5, σ 2
+	b := NewBollinger(8, 2)
+	for _, price := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		b.UpdateAndCommit(price)
+	}
+	if !b.Ready() {
+		t.Fatal("not ready after a full window")
+	}
+	want := BollingerValue{Middle: 5, Upper: 9, Lower: 1, PercentB: 1, Bandwidth: 1.6}
+	if got := b.Value; got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// Calculate replaces the oldest close (2) without committing
+	preview := b.Calculate(2)
+	if wantPreview := (BollingerValue{Middle: 5, Upper: 9, Lower: 1, PercentB: 0.125, Bandwidth: 1.6}); preview != wantPreview {
+		t.Fatalf("preview %+v, want %+v", preview, wantPreview)
+	}
+	if b.Value != want {
+		t.Fatal("Calculate changed the committed value")
+	}
+
+	// Rolling on: 4 4 4 5 5 7 9 10, mean 6, σ² = 5
+	got := b.UpdateAndCommit(10)
+	if !near(got.Middle, 6, 1e-12) || !near(got.Upper, 6+2*math.Sqrt(5), 1e-12) || !near(got.Lower, 6-2*math.Sqrt(5), 1e-12) {
+		t.Fatalf("rolled window %+v", got)
+	}
+}
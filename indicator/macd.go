@@ -0,0 +1,41 @@
+package indicator
+
+// MACDValue is one MACD reading.
+type MACDValue struct {
+	MACD      float64 // fast EMA - slow EMA
+	Signal    float64 // EMA of the MACD line
+	Histogram float64 // MACD - Signal
+}
+
+// MACD is Moving Average Convergence Divergence built from three EMAs. The
+// EMAs are seeded with the first price rather than a simple average. It is
+// not used by the pipeline yet; only RSI feeds the scorer.
+type MACD struct {
+	fast   *EMA
+	slow   *EMA
+	signal *EMA
+	Value  MACDValue
+}
+
+func NewMACD(fastPeriod, slowPeriod, signalPeriod int) *MACD {
+	return &MACD{
+		fast:   NewEMA(fastPeriod),
+		slow:   NewEMA(slowPeriod),
+		signal: NewEMA(signalPeriod),
+	}
+}
+
+// UpdateAndCommit adds a closed candle's price and returns the new reading
+func (m *MACD) UpdateAndCommit(price float64) MACDValue {
+	line := m.fast.UpdateAndCommit(price) - m.slow.UpdateAndCommit(price)
+	signal := m.signal.UpdateAndCommit(line)
+	m.Value = MACDValue{MACD: line, Signal: signal, Histogram: line - signal}
+	return m.Value
+}
+
+// Calculate returns the reading for a given price without updating the state
+func (m *MACD) Calculate(price float64) MACDValue {
+	line := m.fast.Calculate(price) - m.slow.Calculate(price)
+	signal := m.signal.Calculate(line)
+	return MACDValue{MACD: line, Signal: signal, Histogram: line - signal}
+}
@@ -0,0 +1,82 @@
+package indicator
+
+// RSI is the Relative Strength Index with Wilder smoothing.
+// The first Period changes seed the averages with a simple mean.
+type RSI struct {
+	Period int
+	state  rsiState
+}
+
+type rsiState struct {
+	prevClose   float64
+	avgGain     float64
+	avgLoss     float64
+	changes     int
+	initialized bool
+}
+
+func NewRSI(period int) *RSI {
+	return &RSI{Period: period}
+}
+
+// Ready reports whether enough closes have been committed for a seeded value.
+func (r *RSI) Ready() bool {
+	return r.state.changes >= r.Period
+}
+
+// Value returns the RSI of the committed closes.
+func (r *RSI) Value() float64 {
+	return r.state.value()
+}
+
+// UpdateAndCommit adds a closed candle's price and returns the new RSI
+func (r *RSI) UpdateAndCommit(price float64) float64 {
+	r.state = r.state.next(price, r.Period)
+	return r.state.value()
+}
+
+// Calculate returns the RSI for a given price without updating the state
+func (r *RSI) Calculate(price float64) float64 {
+	next := r.state.next(price, r.Period)
+	return next.value()
+}
+
+func (s rsiState) next(price float64, period int) rsiState {
+	if !s.initialized {
+		return rsiState{prevClose: price, initialized: true}
+	}
+
+	change := price - s.prevClose
+	gain, loss := 0.0, 0.0
+	if change > 0 {
+		gain = change
+	} else {
+		loss = -change
+	}
+
+	s.changes++
+	if s.changes <= period {
+		// Simple mean while seeding
+		n := float64(s.changes)
+		s.avgGain += (gain - s.avgGain) / n
+		s.avgLoss += (loss - s.avgLoss) / n
+	} else {
+		// Wilder smoothing: avg = (prevAvg * (n-1) + current) / n
+		n := float64(period)
+		s.avgGain = (s.avgGain*(n-1) + gain) / n
+		s.avgLoss = (s.avgLoss*(n-1) + loss) / n
+	}
+	s.prevClose = price
+	return s
+}
+
+func (s rsiState) value() float64 {
+	if s.changes == 0 || (s.avgGain == 0 && s.avgLoss == 0) {
+		return 50
+	}
+	if s.avgLoss == 0 {
+		return 100
+	}
+	rs := s.avgGain / s.avgLoss
+	return 100 - 100/(1+rs)
+}
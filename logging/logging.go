@@ -0,0 +1,307 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"fibo-monitor/config"
+	"fibo-monitor/redact"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logging builds the component loggers. Every component has its own level,
+// which follows the root level unless set explicitly in the config or at
+// runtime.
+type Logging struct {
+	encoder    zapcore.Encoder
+	sink       zapcore.WriteSyncer
+	errorSink  zapcore.WriteSyncer
+	sampling   config.SamplingConfig
+	root       zap.AtomicLevel
+	rootLogger *zap.Logger
+	components map[string]*component
+	mu         sync.Mutex
+}
+
+type component struct {
+	level zap.AtomicLevel
+	// explicit is set when the level does not follow the root level
+	explicit bool
+	// logger is built once so its sampler counts every entry of the component
+	logger *zap.Logger
+}
+
+// New creates the loggers described by the monitoring config.
+func New(cfg config.MonitoringConfig) (*Logging, error) {
+	root, err := zap.ParseAtomicLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	lc := cfg.Logging
+	encoderCfg := zap.NewProductionEncoderConfig()
+	var encoder zapcore.Encoder
+	switch lc.Encoding {
+	case "json", "":
+		encoder = zapcore.NewJSONEncoder(encoderCfg)
+	case "console":
+		encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoderCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderCfg)
+	default:
+		return nil, fmt.Errorf("unknown log encoding %q", lc.Encoding)
+	}
+
+	outputs, errorOutputs := lc.OutputPaths, lc.ErrorOutputPaths
+	if len(outputs) == 0 {
+		outputs = []string{"stderr"}
+	}
+	if len(errorOutputs) == 0 {
+		errorOutputs = []string{"stderr"}
+	}
+	sink, closeSink, err := zap.Open(outputs...)
+	if err != nil {
+		return nil, err
+	}
+	errorSink, _, err := zap.Open(errorOutputs...)
+	if err != nil {
+		closeSink()
+		return nil, err
+	}
+
+	l := &Logging{
+		encoder:    encoder,
+		sink:       redactingSink{sink},
+		errorSink:  redactingSink{errorSink},
+		sampling:   lc.Sampling,
+		root:       root,
+		components: make(map[string]*component),
+	}
+	l.rootLogger = l.build("", root)
+	for name, level := range lc.Components {
+		if err := l.SetLevel(name, level); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Logger returns the logger of a component, e.g. "websocket" or "signal".
+// An empty name returns the root logger. Repeated calls return the same
+// logger, so sampling applies per component.
+func (l *Logging) Logger(name string) *zap.Logger {
+	if name == "" {
+		return l.rootLogger
+	}
+	return l.component(name).logger
+}
+
+func (l *Logging) build(name string, level zap.AtomicLevel) *zap.Logger {
+	var core zapcore.Core = redactingCore{zapcore.NewCore(l.encoder.Clone(), l.sink, level)}
+	if l.sampling.Enabled {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, l.sampling.Initial, l.sampling.Thereafter)
+	}
+	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel), zap.ErrorOutput(l.errorSink))
+	if name != "" {
+		logger = logger.Named(name)
+	}
+	return logger
+}
+
+func (l *Logging) component(name string) *component {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.components[name]
+	if !ok {
+		c = &component{level: zap.NewAtomicLevelAt(l.root.Level())}
+		c.logger = l.build(name, c.level)
+		l.components[name] = c
+	}
+	return c
+}
+
+// SetLevel changes the level of a component, or of the root logger and every
+// component following it when name is empty. Loggers already handed out
+// pick up the change immediately.
+func (l *Logging) SetLevel(name, level string) error {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		c := l.component(name)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		c.explicit = true
+		c.level.SetLevel(lvl)
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.root.SetLevel(lvl)
+	for _, c := range l.components {
+		if !c.explicit {
+			c.level.SetLevel(lvl)
+		}
+	}
+	return nil
+}
+
+// ResetLevel makes a component follow the root level again.
+func (l *Logging) ResetLevel(name string) {
+	c := l.component(name)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c.explicit = false
+	c.level.SetLevel(l.root.Level())
+}
+
+// Apply updates the levels from a reloaded config.
+func (l *Logging) Apply(cfg config.MonitoringConfig) error {
+	if err := l.SetLevel("", cfg.LogLevel); err != nil {
+		return err
+	}
+	for _, name := range l.names() {
+		if _, ok := cfg.Logging.Components[name]; !ok {
+			l.ResetLevel(name)
+		}
+	}
+	for name, level := range cfg.Logging.Components {
+		if err := l.SetLevel(name, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Logging) names() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, 0, len(l.components))
+	for name := range l.components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Levels describes the current levels.
+type Levels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+func (l *Logging) Levels() Levels {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	levels := Levels{Level: l.root.Level().String(), Components: make(map[string]string)}
+	for name, c := range l.components {
+		levels.Components[name] = c.level.Level().String()
+	}
+	return levels
+}
+
+// ServeHTTP shows the levels on GET. PUT or POST a JSON body such as
+// {"component": "websocket", "level": "debug"} to change a level; without a
+// component the root level changes, and an empty level resets a component
+// to the root level.
+func (l *Logging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Component string `json:"component"`
+			Level     string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Component != "" && req.Level == "" {
+			l.ResetLevel(req.Component)
+		} else if err := l.SetLevel(req.Component, req.Level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l.Logger("").Info("Log level changed",
+			zap.String("component", req.Component),
+			zap.String("level", req.Level),
+		)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l.Levels())
+}
+
+// redactingCore masks secret values in the message and in string, byte
+// string, error and stringer fields before they are encoded, so escaping by
+// the encoder cannot hide them from redaction.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c redactingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = redact.String(ent.Message)
+	return c.Core.Write(ent, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = redact.String(f.String)
+		case zapcore.ByteStringType:
+			f.Interface = redact.Bytes(f.Interface.([]byte))
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok {
+				f.Interface = redact.Error(err)
+			}
+		case zapcore.StringerType:
+			if s, ok := f.Interface.(fmt.Stringer); ok {
+				f = zap.String(f.Key, redact.String(s.String()))
+			}
+		}
+		out[i] = f
+	}
+	return out
+}
+
+// redactingSink masks secret values left in encoded entries, such as those
+// inside reflected or nested objects, wherever in the entry they appear.
+type redactingSink struct {
+	zapcore.WriteSyncer
+}
+
+func (s redactingSink) Write(p []byte) (int, error) {
+	if _, err := s.WriteSyncer.Write(redact.Bytes(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Sync flushes buffered log entries.
+func (l *Logging) Sync() error {
+	return l.sink.Sync()
+}
@@ -0,0 +1,53 @@
+package logging_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fibo-monitor/config"
+	"fibo-monitor/logging"
+	"fibo-monitor/redact"
+
+	"go.uber.org/zap"
+)
+
+func TestSecretsRedactedDespiteEscaping(t *testing.T) {
+	// Quotes, backslashes, HTML characters and non-ASCII are all escaped
+	// differently by the JSON encoder
+	secret := `hook"se\cret<&>é`
+	redact.Add(secret)
+
+	path := filepath.Join(t.TempDir(), "log.json")
+	logs, err := logging.New(config.MonitoringConfig{
+		LogLevel: "info",
+		Logging:  config.LoggingConfig{OutputPaths: []string{path}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logs.Logger("notification").With(zap.String("url", "https://example.com/"+secret))
+	logger.Info("posting to "+secret,
+		zap.String("secret", secret),
+		zap.Error(errors.New("dial "+secret+": refused")),
+		zap.ByteString("payload", []byte(secret)),
+		zap.Any("nested", map[string]string{"secret": secret}),
+	)
+	logs.Sync()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, leaked := range []string{"se\\cret", `se\\cret`, `hook\"`, "cret<&>"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("log contains %q: %s", leaked, out)
+		}
+	}
+	if n := strings.Count(out, redact.Placeholder); n != 6 {
+		t.Errorf("got %d placeholders, want 6: %s", n, out)
+	}
+}
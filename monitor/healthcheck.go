@@ -1,8 +1,12 @@
 package monitor
 
 import (
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"fibo-monitor/config"
@@ -12,22 +16,73 @@ import (
 
 type Server struct {
 	config config.MonitoringConfig
+	mux    *http.ServeMux
 	logger *zap.Logger
 }
 
 func NewServer(cfg config.MonitoringConfig, logger *zap.Logger) *Server {
 	return &Server{
 		config: cfg,
+		mux:    http.NewServeMux(),
 		logger: logger,
 	}
 }
 
+// Handle registers an additional endpoint. It must be called before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleAdmin registers an endpoint that requires the admin token as a bearer
+// token, or without a configured token, a request from localhost.
+func (s *Server) HandleAdmin(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			s.logger.Warn("Unauthorized admin request",
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr),
+			)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if token := s.config.AdminToken; token != "" {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// HandleJSON registers an endpoint that serves the value returned by fn as JSON.
+func (s *Server) HandleJSON(pattern string, fn func() interface{}) {
+	s.mux.Handle(pattern, s.JSONHandler(fn))
+}
+
+// JSONHandler serves the value returned by fn as JSON.
+func (s *Server) JSONHandler(fn func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(fn()); err != nil {
+			s.logger.Error("Failed to encode response", zap.String("path", r.URL.Path), zap.Error(err))
+		}
+	})
+}
+
 func (s *Server) Start() {
 	go s.startHealthCheck()
 }
 
 func (s *Server) startHealthCheck() {
-	mux := http.NewServeMux()
+	mux := s.mux
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
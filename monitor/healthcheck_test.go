@@ -0,0 +1,46 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fibo-monitor/config"
+
+	"go.uber.org/zap"
+)
+
+func TestHandleAdmin(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name   string
+		token  string
+		remote string
+		auth   string
+		want   int
+	}{
+		{"localhost without token", "", "127.0.0.1:5000", "", http.StatusOK},
+		{"ipv6 localhost without token", "", "[::1]:5000", "", http.StatusOK},
+		{"remote without token", "", "10.0.0.2:5000", "", http.StatusUnauthorized},
+		{"remote with token", "secret", "10.0.0.2:5000", "Bearer secret", http.StatusOK},
+		{"wrong token", "secret", "10.0.0.2:5000", "Bearer nope", http.StatusUnauthorized},
+		{"localhost needs configured token", "secret", "127.0.0.1:5000", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(config.MonitoringConfig{AdminToken: tt.token}, zap.NewNop())
+			s.HandleAdmin("/state", ok)
+
+			req := httptest.NewRequest(http.MethodPost, "/state", nil)
+			req.RemoteAddr = tt.remote
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			s.mux.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package notification
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fibo-monitor/config"
+	"fibo-monitor/data/kline"
+	"fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+// chartCandles is the number of closed candles drawn by the chart action.
+const chartCandles = 30
+
+// maxCallbackAge bounds the age of a signed callback's timestamp, so captured
+// requests cannot be replayed later.
+const maxCallbackAge = 5 * time.Minute
+
+// CardRegistry remembers recently sent signals so their cards can be
+// re-rendered when a button is clicked.
+type CardRegistry struct {
+	size  int
+	cards map[string]*sentCard
+	order []string
+	mu    sync.Mutex
+}
+
+type sentCard struct {
+	signal signal.Signal
+	notes  []string
+}
+
+func NewCardRegistry(size int) *CardRegistry {
+	return &CardRegistry{
+		size:  size,
+		cards: make(map[string]*sentCard),
+	}
+}
+
+// Add records a sent signal, forgetting the oldest one when full.
+func (r *CardRegistry) Add(sig signal.Signal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := sig.ID()
+	if _, ok := r.cards[id]; ok {
+		return
+	}
+	r.cards[id] = &sentCard{signal: sig}
+	r.order = append(r.order, id)
+	if len(r.order) > r.size {
+		delete(r.cards, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+// lookup returns a sent signal by id.
+func (r *CardRegistry) lookup(id string) (signal.Signal, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	card, ok := r.cards[id]
+	if !ok {
+		return signal.Signal{}, false
+	}
+	return card.signal, true
+}
+
+// annotate appends a note to a sent card and returns its signal and notes.
+func (r *CardRegistry) annotate(id, note string) (signal.Signal, []string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	card, ok := r.cards[id]
+	if !ok {
+		return signal.Signal{}, nil, false
+	}
+	if note != "" {
+		card.notes = append(card.notes, note)
+	}
+	return card.signal, append([]string(nil), card.notes...), true
+}
+
+// CallbackHandler answers Lark card button clicks: acknowledging a signal,
+// muting its symbol/interval, or attaching a chart, then returns the
+// updated card showing who acted.
+type CallbackHandler struct {
+	config config.LarkCallbackConfig
+	sender *WebhookSender
+	mutes  *signal.MuteList
+	store  *kline.Store
+	logger *zap.Logger
+}
+
+func NewCallbackHandler(cfg config.LarkCallbackConfig, sender *WebhookSender, mutes *signal.MuteList, store *kline.Store, logger *zap.Logger) *CallbackHandler {
+	return &CallbackHandler{
+		config: cfg,
+		sender: sender,
+		mutes:  mutes,
+		store:  store,
+		logger: logger,
+	}
+}
+
+// callbackRequest covers both the URL verification challenge and card actions.
+type callbackRequest struct {
+	Type          string `json:"type"`
+	Challenge     string `json:"challenge"`
+	Token         string `json:"token"`
+	OpenID        string `json:"open_id"`
+	UserID        string `json:"user_id"`
+	OpenMessageID string `json:"open_message_id"`
+	Action        struct {
+		Tag   string                 `json:"tag"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"action"`
+}
+
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Without either secret anyone reaching the port could act on cards
+	if h.config.EncryptKey == "" && h.config.VerificationToken == "" {
+		h.logger.Error("Rejected Lark callback: neither encrypt_key nor verification_token is configured")
+		http.Error(w, "callback not configured", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.verifySignature(r.Header, body); err != nil {
+		h.logger.Warn("Rejected Lark callback", zap.Error(err))
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	payload, err := h.decrypt(body)
+	if err != nil {
+		h.logger.Warn("Failed to decrypt Lark callback", zap.Error(err))
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	var req callbackRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if h.config.VerificationToken != "" && req.Token != h.config.VerificationToken {
+		h.logger.Warn("Lark callback with invalid verification token")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	if req.Type == "url_verification" {
+		writeJSON(w, map[string]string{"challenge": req.Challenge})
+		return
+	}
+
+	card, err := h.handleAction(req)
+	if err != nil {
+		h.logger.Warn("Failed to handle Lark card action", zap.Error(err))
+		// Lark shows a toast for an empty response; keep the card unchanged
+		writeJSON(w, map[string]interface{}{})
+		return
+	}
+	writeJSON(w, card)
+}
+
+func (h *CallbackHandler) handleAction(req callbackRequest) (CardBody, error) {
+	value := req.Action.Value
+	action, _ := value["action"].(string)
+	id, _ := value["signal_id"].(string)
+
+	// Only act on pairs of cards actually sent, whatever the payload claims
+	sent, ok := h.sender.cards.lookup(id)
+	if !ok {
+		return CardBody{}, fmt.Errorf("unknown or expired signal %q", id)
+	}
+	symbol, interval := sent.Symbol, sent.Interval
+
+	who := req.OpenID
+	if req.UserID != "" {
+		who = req.UserID
+	}
+	mention := fmt.Sprintf("<at id=%s></at>", who)
+	now := h.sender.clock.Now().Format("2006-01-02 15:04:05")
+
+	var note string
+	var extra []interface{}
+	switch action {
+	case ActionAck, ActionIgnore:
+		note = fmt.Sprintf("✅ %s 已确认 · %s", mention, now)
+	case ActionMute:
+		hours, _ := value["hours"].(float64)
+		if hours <= 0 {
+			return CardBody{}, fmt.Errorf("invalid mute duration %v", value["hours"])
+		}
+		until := h.sender.clock.Now().Add(time.Duration(hours * float64(time.Hour)))
+		h.mutes.Mute(symbol, interval, until)
+		note = fmt.Sprintf("🔕 %s 已静音 %s %s 至 %s", mention, symbol, interval, until.Format("2006-01-02 15:04"))
+	case ActionChart:
+		extra = append(extra, h.chart(symbol, interval))
+		note = fmt.Sprintf("📊 %s 查看了走势 · %s", mention, now)
+	default:
+		return CardBody{}, fmt.Errorf("unknown action %q", action)
+	}
+
+	sig, notes, ok := h.sender.cards.annotate(id, note)
+	if !ok {
+		// Evicted since the lookup
+		return CardBody{}, fmt.Errorf("unknown or expired signal %q", id)
+	}
+	h.logger.Info("Lark card action",
+		zap.String("action", action),
+		zap.String("signal_id", id),
+		zap.String("user", who),
+	)
+
+	for _, n := range notes {
+		extra = append(extra, NoteElement{
+			Tag:      "note",
+			Elements: []TagText{{Tag: "lark_md", Content: n}},
+		})
+	}
+	return h.sender.messageCard().BuildCard(sig, extra...), nil
+}
+
+// chart renders the recent closes of a pair as a text sparkline.
+func (h *CallbackHandler) chart(symbol, interval string) DivElement {
+	candles := h.store.Last(symbol, interval, chartCandles)
+	if live, ok := h.store.Live(symbol, interval); ok {
+		candles = append(candles, live)
+	}
+	if len(candles) == 0 {
+		return DivElement{Tag: "div", Text: TagText{Tag: "lark_md", Content: "暂无 K 线数据"}}
+	}
+
+	low, high := candles[0].Low, candles[0].High
+	for _, c := range candles {
+		if c.Low < low {
+			low = c.Low
+		}
+		if c.High > high {
+			high = c.High
+		}
+	}
+
+	bars := []rune("▁▂▃▄▅▆▇█")
+	var line strings.Builder
+	for _, c := range candles {
+		i := 0
+		if high > low {
+			i = int((c.Close - low) / (high - low) * float64(len(bars)-1))
+		}
+		line.WriteRune(bars[i])
+	}
+
+	return DivElement{
+		Tag: "div",
+		Text: TagText{
+			Tag: "lark_md",
+			Content: fmt.Sprintf("**%s %s 最近 %d 根 K 线**\n%s\n最高 %s · 最低 %s · 最新 %s",
+				symbol, interval, len(candles), line.String(), formatPrice(high), formatPrice(low), formatPrice(candles[len(candles)-1].Close)),
+		},
+	}
+}
+
+// verifySignature requires X-Lark-Signature when an encrypt key is
+// configured: sha256(timestamp + nonce + encrypt_key + body), with a
+// timestamp no further than maxCallbackAge from now.
+func (h *CallbackHandler) verifySignature(header http.Header, body []byte) error {
+	if h.config.EncryptKey == "" {
+		return nil
+	}
+	sig := header.Get("X-Lark-Signature")
+	if sig == "" {
+		return errors.New("missing signature")
+	}
+	expected := LarkSignature(header.Get("X-Lark-Request-Timestamp"), header.Get("X-Lark-Request-Nonce"), h.config.EncryptKey, body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return errors.New("signature mismatch")
+	}
+	ts, err := strconv.ParseInt(header.Get("X-Lark-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if age := h.sender.clock.Now().Sub(time.Unix(ts, 0)); age > maxCallbackAge || age < -maxCallbackAge {
+		return fmt.Errorf("timestamp %d is %v off", ts, age.Round(time.Second))
+	}
+	return nil
+}
+
+// decrypt unwraps {"encrypt": "..."} bodies. Plain bodies are only accepted
+// without an encrypt key.
+func (h *CallbackHandler) decrypt(body []byte) ([]byte, error) {
+	var wrapped struct {
+		Encrypt string `json:"encrypt"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil || wrapped.Encrypt == "" {
+		if h.config.EncryptKey != "" {
+			return nil, errors.New("unencrypted callback but encrypt_key is configured")
+		}
+		return body, nil
+	}
+	if h.config.EncryptKey == "" {
+		return nil, errors.New("encrypted callback but no encrypt_key configured")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(wrapped.Encrypt)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte(h.config.EncryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid ciphertext length")
+	}
+
+	iv, data := data[:aes.BlockSize], data[aes.BlockSize:]
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// PKCS#7 padding
+	if len(plain) == 0 {
+		return nil, errors.New("empty plaintext")
+	}
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(plain) {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// LarkSignature computes the X-Lark-Signature header value.
+func LarkSignature(timestamp, nonce, encryptKey string, body []byte) string {
+	sum := sha256.Sum256(append([]byte(timestamp+nonce+encryptKey), body...))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,222 @@
+package notification_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/indicator"
+	"fibo-monitor/notification"
+	"fibo-monitor/notification/larktest"
+	"fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+const (
+	encryptKey        = "test-encrypt-key"
+	verificationToken = "test-token"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type callbackFixture struct {
+	server *httptest.Server
+	clock  *clock.Simulated
+	mutes  *signal.MuteList
+	sig    signal.Signal
+}
+
+func newCallbackFixture(t *testing.T, key, token string) *callbackFixture {
+	t.Helper()
+	clk := clock.NewSimulated(start)
+	logger := zap.NewNop()
+
+	sender := notification.NewWebhookSender(
+		config.WebhookConfig{Enabled: true, MinSeverity: "info"},
+		config.MessageCardConfig{Title: "test"},
+		clk, logger,
+	)
+	sender.SetDryRun(true)
+	sig := signal.Signal{
+		Type:      indicator.GoldenCross,
+		Symbol:    "BTCUSDT",
+		Interval:  "1h",
+		Price:     100,
+		Timestamp: start,
+	}
+	sender.Send(sig)
+
+	mutes := signal.NewMuteList(clk, logger)
+	handler := notification.NewCallbackHandler(
+		config.LarkCallbackConfig{Enabled: true, Path: "/lark/callback", EncryptKey: key, VerificationToken: token},
+		sender, mutes, nil, logger,
+	)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &callbackFixture{server: server, clock: clk, mutes: mutes, sig: sig}
+}
+
+func TestCallbackEncryptedAck(t *testing.T) {
+	f := newCallbackFixture(t, encryptKey, "")
+	sender := larktest.NewSender(f.server.URL, "", encryptKey)
+	sender.Now = f.clock.Now
+
+	card, err := sender.Click("ou_1", "om_1", map[string]interface{}{
+		"action":    notification.ActionAck,
+		"signal_id": f.sig.ID(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(card.Elements) == 0 {
+		t.Fatal("expected the updated card")
+	}
+	challenge, err := sender.Verify("abc")
+	if err != nil || challenge != "abc" {
+		t.Fatalf("challenge = %q, %v", challenge, err)
+	}
+}
+
+func TestCallbackRejectsUnsigned(t *testing.T) {
+	f := newCallbackFixture(t, encryptKey, "")
+
+	body := `{"open_id":"ou_1","action":{"tag":"button","value":{"action":"mute","hours":1,"signal_id":"` + f.sig.ID() + `"}}}`
+	resp, err := http.Post(f.server.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if f.mutes.IsMuted(f.sig.Symbol, f.sig.Interval, start) {
+		t.Fatal("unsigned request muted the pair")
+	}
+}
+
+func TestCallbackRejectsBadSignature(t *testing.T) {
+	f := newCallbackFixture(t, encryptKey, "")
+
+	body := []byte(`{"encrypt":"AAAAAAAAAAAAAAAAAAAAAA=="}`)
+	req, _ := http.NewRequest(http.MethodPost, f.server.URL, bytes.NewReader(body))
+	req.Header.Set("X-Lark-Request-Timestamp", "1")
+	req.Header.Set("X-Lark-Request-Nonce", "n")
+	req.Header.Set("X-Lark-Signature", notification.LarkSignature("1", "n", "wrong-key", body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestCallbackRejectsSignedPlainBody(t *testing.T) {
+	f := newCallbackFixture(t, encryptKey, "")
+
+	body := []byte(`{"type":"url_verification","challenge":"abc"}`)
+	req, _ := http.NewRequest(http.MethodPost, f.server.URL, bytes.NewReader(body))
+	ts := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("X-Lark-Request-Timestamp", ts)
+	req.Header.Set("X-Lark-Request-Nonce", "n")
+	req.Header.Set("X-Lark-Signature", notification.LarkSignature(ts, "n", encryptKey, body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestCallbackUnknownSignalDoesNotMute(t *testing.T) {
+	f := newCallbackFixture(t, "", verificationToken)
+	sender := larktest.NewSender(f.server.URL, verificationToken, "")
+
+	card, err := sender.Click("ou_1", "om_1", map[string]interface{}{
+		"action":    notification.ActionMute,
+		"hours":     1.0,
+		"signal_id": "ETHUSDT-1h-GOLDEN-0",
+		"symbol":    "ETHUSDT",
+		"interval":  "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(card.Elements) != 0 {
+		t.Fatal("expected no card for an unknown signal")
+	}
+	if f.mutes.IsMuted("ETHUSDT", "1h", start) {
+		t.Fatal("unknown signal muted a pair")
+	}
+}
+
+func TestCallbackMuteExpires(t *testing.T) {
+	f := newCallbackFixture(t, "", verificationToken)
+	sender := larktest.NewSender(f.server.URL, verificationToken, "")
+
+	// The pair comes from the sent signal, not the payload
+	_, err := sender.Click("ou_1", "om_1", map[string]interface{}{
+		"action":    notification.ActionMute,
+		"hours":     2.0,
+		"signal_id": f.sig.ID(),
+		"symbol":    "ETHUSDT",
+		"interval":  "4h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.mutes.IsMuted("ETHUSDT", "4h", start) {
+		t.Fatal("payload pair was muted")
+	}
+	if !f.mutes.IsMuted(f.sig.Symbol, f.sig.Interval, start.Add(time.Hour)) {
+		t.Fatal("pair not muted after one hour")
+	}
+	if f.mutes.IsMuted(f.sig.Symbol, f.sig.Interval, start.Add(2*time.Hour)) {
+		t.Fatal("pair still muted after two hours")
+	}
+}
+
+func TestCallbackRejectsReplayedRequest(t *testing.T) {
+	f := newCallbackFixture(t, encryptKey, "")
+	sender := larktest.NewSender(f.server.URL, "", encryptKey)
+	sender.Now = func() time.Time { return start.Add(-10 * time.Minute) }
+
+	_, err := sender.Click("ou_1", "om_1", map[string]interface{}{
+		"action":    notification.ActionMute,
+		"hours":     1.0,
+		"signal_id": f.sig.ID(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("stale request not rejected: %v", err)
+	}
+	if f.mutes.IsMuted(f.sig.Symbol, f.sig.Interval, start) {
+		t.Fatal("stale request muted the pair")
+	}
+}
+
+func TestCallbackRefusesWithoutSecrets(t *testing.T) {
+	f := newCallbackFixture(t, "", "")
+	sender := larktest.NewSender(f.server.URL, "", "")
+
+	_, err := sender.Click("ou_1", "om_1", map[string]interface{}{
+		"action":    notification.ActionMute,
+		"hours":     1.0,
+		"signal_id": f.sig.ID(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("request without secrets not refused: %v", err)
+	}
+	if f.mutes.IsMuted(f.sig.Symbol, f.sig.Interval, start) {
+		t.Fatal("request muted the pair")
+	}
+}
@@ -0,0 +1,322 @@
+package notification
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fibo-monitor/indicator"
+	"fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+type ColumnSet struct {
+	Tag      string   `json:"tag"`
+	FlexMode string   `json:"flex_mode"`
+	Columns  []Column `json:"columns"`
+}
+
+type Column struct {
+	Tag      string        `json:"tag"`
+	Width    string        `json:"width"`
+	Weight   int           `json:"weight"`
+	Elements []interface{} `json:"elements"`
+}
+
+// digestColumns are the headers of the digest table.
+var digestColumns = []string{"交易对", "周期", "信号", "价格", "强度"}
+
+// maxDigestRows keeps digest cards within Lark's card size limit.
+const maxDigestRows = 30
+
+// BuildDigestCard renders several signals as one card with a table row per signal.
+func (m *MessageCard) BuildDigestCard(sigs []signal.Signal) CardBody {
+	template := "blue"
+	if m.Config.ThemeColor != "" {
+		template = larkTemplate(m.Config.ThemeColor)
+	}
+	// Colored by the most severe signals, and their direction if they share one
+	highest := signal.SeverityInfo
+	for _, sig := range sigs {
+		if sig.Severity > highest {
+			highest = sig.Severity
+		}
+	}
+	cross := indicator.None
+	for _, sig := range sigs {
+		if sig.Severity != highest {
+			continue
+		}
+		if cross != indicator.None && sig.Type != cross {
+			cross = indicator.None
+			break
+		}
+		cross = sig.Type
+	}
+	if t, ok := severityTemplate(cross, highest); ok {
+		template = t
+	}
+
+	title := fmt.Sprintf("📋 信号汇总 (%d)", len(sigs))
+	if m.Config.Title != "" {
+		title = m.Config.Title + " | " + title
+	}
+
+	elements := []interface{}{tableRow(digestColumns, true)}
+	shown := sigs
+	if len(shown) > maxDigestRows {
+		shown = shown[len(shown)-maxDigestRows:]
+	}
+	for _, sig := range shown {
+		direction := "📈 金叉"
+		if sig.Type == indicator.DeathCross {
+			direction = "📉 死叉"
+		}
+		if sig.Confluence {
+			direction += " 🔥"
+		}
+		elements = append(elements, tableRow([]string{
+			sig.Symbol,
+			sig.Interval,
+			direction,
+			formatPrice(sig.Price),
+			fmt.Sprintf("%.0f (%s)", sig.Score, sig.Severity),
+		}, false))
+	}
+
+	if hidden := len(sigs) - len(shown); hidden > 0 {
+		elements = append(elements, NoteElement{
+			Tag:      "note",
+			Elements: []TagText{{Tag: "lark_md", Content: fmt.Sprintf("另有 %d 条较早的信号未显示", hidden)}},
+		})
+	}
+
+	if m.Config.IncludeTimestamp && len(sigs) > 0 {
+		elements = append(elements, NoteElement{
+			Tag: "note",
+			Elements: []TagText{{
+				Tag: "lark_md",
+				Content: fmt.Sprintf("%s ~ %s",
+					sigs[0].Timestamp.Format("2006-01-02 15:04:05"),
+					sigs[len(sigs)-1].Timestamp.Format("15:04:05")),
+			}},
+		})
+	}
+
+	return CardBody{
+		Header: CardHeader{
+			Template: template,
+			Title: TagText{
+				Tag:     "plain_text",
+				Content: title,
+			},
+		},
+		Elements: elements,
+	}
+}
+
+func tableRow(cells []string, header bool) ColumnSet {
+	row := ColumnSet{Tag: "column_set", FlexMode: "none"}
+	for _, cell := range cells {
+		if header {
+			cell = "**" + cell + "**"
+		}
+		row.Columns = append(row.Columns, Column{
+			Tag:    "column",
+			Width:  "weighted",
+			Weight: 1,
+			Elements: []interface{}{
+				DivElement{Tag: "div", Text: TagText{Tag: "lark_md", Content: cell}},
+			},
+		})
+	}
+	return row
+}
+
+// Batcher collects signals for a short window and delivers them together,
+// so a market-wide move produces one digest instead of a burst of cards.
+type Batcher struct {
+	window  time.Duration
+	sender  *WebhookSender
+	pending []signal.Signal
+	mu      sync.Mutex
+	logger  *zap.Logger
+}
+
+func NewBatcher(window time.Duration, sender *WebhookSender, logger *zap.Logger) *Batcher {
+	return &Batcher{
+		window: window,
+		sender: sender,
+		logger: logger,
+	}
+}
+
+// Send queues a signal; the window starts with the first queued signal.
+func (b *Batcher) Send(sig signal.Signal) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) == 0 {
+		go func() {
+			<-b.sender.clock.After(b.window)
+			b.Flush()
+		}()
+	}
+	b.pending = append(b.pending, sig)
+}
+
+// Flush delivers the queued signals immediately.
+func (b *Batcher) Flush() {
+	b.mu.Lock()
+	sigs := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(sigs) == 0 {
+		return
+	}
+	b.logger.Info("Sending signal digest", zap.Int("signals", len(sigs)))
+	b.sender.SendDigest(sigs)
+}
+
+// Summarizer periodically sends an overview of recent signals, the current
+// EMA trend of every monitored pair and webhook delivery statistics.
+type Summarizer struct {
+	interval  time.Duration
+	symbols   []string
+	intervals []string
+	trends    signal.TrendSource
+	sender    *WebhookSender
+	// counts: "SYMBOL interval TYPE" -> signals since the last summary
+	counts map[string]int
+	since  time.Time
+	mu     sync.Mutex
+	stop   chan struct{}
+	once   sync.Once
+	logger *zap.Logger
+}
+
+func NewSummarizer(interval time.Duration, symbols, intervals []string, trends signal.TrendSource, sender *WebhookSender, logger *zap.Logger) *Summarizer {
+	return &Summarizer{
+		interval:  interval,
+		symbols:   symbols,
+		intervals: intervals,
+		trends:    trends,
+		sender:    sender,
+		counts:    make(map[string]int),
+		since:     sender.clock.Now(),
+		stop:      make(chan struct{}),
+		logger:    logger,
+	}
+}
+
+// SetPairs replaces the symbols and intervals listed in the trend table.
+func (s *Summarizer) SetPairs(symbols, intervals []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols = symbols
+	s.intervals = intervals
+}
+
+// Record counts a delivered signal towards the next summary.
+func (s *Summarizer) Record(sig signal.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[fmt.Sprintf("%s %s %s", sig.Symbol, sig.Interval, sig.String())]++
+}
+
+// Start sends a summary at every multiple of the interval (UTC aligned) until
+// Stop is called.
+func (s *Summarizer) Start() {
+	go func() {
+		for {
+			now := s.sender.clock.Now()
+			next := now.Truncate(s.interval).Add(s.interval)
+			select {
+			case <-s.sender.clock.After(next.Sub(now)):
+				s.send()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the summaries started by Start.
+func (s *Summarizer) Stop() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+func (s *Summarizer) send() {
+	s.mu.Lock()
+	counts := s.counts
+	since := s.since
+	symbols, intervals := s.symbols, s.intervals
+	s.counts = make(map[string]int)
+	s.since = s.sender.clock.Now()
+	s.mu.Unlock()
+
+	s.sender.SendCard(s.build(counts, since, symbols, intervals))
+}
+
+func (s *Summarizer) build(counts map[string]int, since time.Time, symbols, intervals []string) CardBody {
+	var lines []string
+	total := 0
+	for key, n := range counts {
+		lines = append(lines, fmt.Sprintf("%s × %d", key, n))
+		total += n
+	}
+	sort.Strings(lines)
+	signalText := "无"
+	if len(lines) > 0 {
+		signalText = strings.Join(lines, "\n")
+	}
+
+	elements := []interface{}{
+		DivElement{Tag: "div", Text: TagText{
+			Tag:     "lark_md",
+			Content: fmt.Sprintf("**信号 (%d)**\n%s", total, signalText),
+		}},
+		DivElement{Tag: "hr"},
+		tableRow(append([]string{"交易对"}, intervals...), true),
+	}
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		cells := []string{symbol}
+		for _, interval := range intervals {
+			trend, ok := s.trends.Trend(symbol, interval)
+			if !ok {
+				cells = append(cells, "-")
+				continue
+			}
+			cells = append(cells, trendArrow(trend))
+		}
+		elements = append(elements, tableRow(cells, false))
+	}
+
+	stats := s.sender.Stats()
+	elements = append(elements,
+		DivElement{Tag: "hr"},
+		NoteElement{Tag: "note", Elements: []TagText{{
+			Tag: "lark_md",
+			Content: fmt.Sprintf("%s ~ %s · 推送成功 %d · 失败 %d",
+				since.Format("2006-01-02 15:04"), s.sender.clock.Now().Format("2006-01-02 15:04"),
+				stats.Delivered, stats.Failed),
+		}}},
+	)
+
+	template := "grey"
+	if themeColor := s.sender.messageCard().Config.ThemeColor; themeColor != "" {
+		template = larkTemplate(themeColor)
+	}
+	return CardBody{
+		Header: CardHeader{
+			Template: template,
+			Title:    TagText{Tag: "plain_text", Content: "🗂 定期汇总"},
+		},
+		Elements: elements,
+	}
+}
@@ -0,0 +1,137 @@
+// Package larktest imitates the Lark platform calling a card callback
+// endpoint, for exercising the callback handler without Lark.
+package larktest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fibo-monitor/notification"
+)
+
+// Sender posts Lark-style callback requests to an endpoint.
+type Sender struct {
+	URL               string
+	VerificationToken string
+	// EncryptKey, when set, encrypts bodies and signs requests like Lark does
+	EncryptKey string
+	Client     *http.Client
+	// Now stamps signed requests; time.Now when nil
+	Now func() time.Time
+}
+
+func NewSender(url, verificationToken, encryptKey string) *Sender {
+	return &Sender{
+		URL:               url,
+		VerificationToken: verificationToken,
+		EncryptKey:        encryptKey,
+		Client:            &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Verify sends the URL verification challenge and returns the echoed challenge.
+func (s *Sender) Verify(challenge string) (string, error) {
+	var resp struct {
+		Challenge string `json:"challenge"`
+	}
+	err := s.post(map[string]interface{}{
+		"type":      "url_verification",
+		"challenge": challenge,
+		"token":     s.VerificationToken,
+	}, &resp)
+	return resp.Challenge, err
+}
+
+// Click sends a button click with the given button value on behalf of a user
+// and returns the updated card.
+func (s *Sender) Click(openID, messageID string, value map[string]interface{}) (notification.CardBody, error) {
+	var card notification.CardBody
+	err := s.post(map[string]interface{}{
+		"open_id":         openID,
+		"user_id":         "",
+		"open_message_id": messageID,
+		"tenant_key":      "fake-tenant",
+		"token":           s.VerificationToken,
+		"action": map[string]interface{}{
+			"tag":   "button",
+			"value": value,
+		},
+	}, &card)
+	return card, err
+}
+
+func (s *Sender) post(payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if s.EncryptKey != "" {
+		encrypted, err := encrypt(s.EncryptKey, body)
+		if err != nil {
+			return err
+		}
+		body, _ = json.Marshal(map[string]string{"encrypt": encrypted})
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.EncryptKey != "" {
+		now := time.Now
+		if s.Now != nil {
+			now = s.Now
+		}
+		timestamp := strconv.FormatInt(now().Unix(), 10)
+		nonce := fmt.Sprintf("%d", time.Now().UnixNano())
+		req.Header.Set("X-Lark-Request-Timestamp", timestamp)
+		req.Header.Set("X-Lark-Request-Nonce", nonce)
+		req.Header.Set("X-Lark-Signature", notification.LarkSignature(timestamp, nonce, s.EncryptKey, body))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return json.Unmarshal(data, out)
+}
+
+// encrypt mirrors Lark: AES-256-CBC with key sha256(encryptKey), a random IV
+// prepended to the ciphertext, PKCS#7 padding, base64 encoded.
+func encrypt(encryptKey string, plain []byte) (string, error) {
+	key := sha256.Sum256([]byte(encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return "", err
+	}
+
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	out := make([]byte, aes.BlockSize+len(padded))
+	if _, err := rand.Read(out[:aes.BlockSize]); err != nil {
+		return "", err
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], padded)
+	return base64.StdEncoding.EncodeToString(out), nil
+}
@@ -2,6 +2,7 @@ package notification
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"fibo-monitor/config"
@@ -51,6 +52,11 @@ type FieldObject struct {
 	Text    TagText `json:"text"`
 }
 
+type NoteElement struct {
+	Tag      string    `json:"tag"`
+	Elements []TagText `json:"elements"`
+}
+
 type ActionElement struct {
 	Tag     string         `json:"tag"`
 	Actions []ButtonObject `json:"actions"`
@@ -64,7 +70,24 @@ type ButtonObject struct {
 	Value map[string]interface{} `json:"value,omitempty"`
 }
 
+// Card button actions handled by the callback endpoint
+const (
+	ActionAck    = "ack"
+	ActionIgnore = "ignore" // same as ack
+	ActionMute   = "mute"
+	ActionChart  = "chart"
+)
+
 func (m *MessageCard) BuildLarkMessage(sig signal.Signal) LarkCard {
+	return LarkCard{
+		MsgType: "interactive",
+		Card:    m.BuildCard(sig),
+	}
+}
+
+// BuildCard renders the card body for a signal. Extra elements are placed
+// between the signal fields and the buttons.
+func (m *MessageCard) BuildCard(sig signal.Signal, extra ...interface{}) CardBody {
 	// Theme color mapping: 
 	// Golden Cross (Bullish) -> Blue/Green -> "blue" or "turquoise"
 	// Death Cross (Bearish) -> Red -> "red" or "carmine"
@@ -74,6 +97,28 @@ func (m *MessageCard) BuildLarkMessage(sig signal.Signal) LarkCard {
 		template = "red"
 		titleText = "📉 死叉信号 (做空)"
 	}
+	if sig.Confluence {
+		titleText = "🔥 共振" + strings.TrimLeft(titleText, "📈📉 ")
+	}
+
+	// A configured theme color replaces the direction color of routine signals
+	if m.Config.ThemeColor != "" {
+		template = larkTemplate(m.Config.ThemeColor)
+	}
+	if m.Config.Title != "" {
+		titleText = m.Config.Title + " | " + titleText
+	}
+
+	// Severity overrides the theme color and is called out in the title
+	if t, ok := severityTemplate(sig.Type, sig.Severity); ok {
+		template = t
+	}
+	switch sig.Severity {
+	case signal.SeverityWarning:
+		titleText = "[重要] " + titleText
+	case signal.SeverityCritical:
+		titleText = "[紧急] " + titleText
+	}
 
 	// Content Fields
 	fields := []FieldObject{
@@ -91,13 +136,16 @@ func (m *MessageCard) BuildLarkMessage(sig signal.Signal) LarkCard {
 				Content: fmt.Sprintf("**周期**\n%s", sig.Interval),
 			},
 		},
-		{
+	}
+
+	if m.Config.IncludePrice {
+		fields = append(fields, FieldObject{
 			IsShort: true,
 			Text: TagText{
 				Tag:     "lark_md",
-				Content: fmt.Sprintf("**当前价格**\n%.2f", sig.Price),
+				Content: "**当前价格**\n" + formatPrice(sig.Price),
 			},
-		},
+		})
 	}
 
 	if m.Config.IncludeEmaValues {
@@ -105,14 +153,14 @@ func (m *MessageCard) BuildLarkMessage(sig signal.Signal) LarkCard {
 			IsShort: true,
 			Text: TagText{
 				Tag:     "lark_md",
-				Content: fmt.Sprintf("**EMA Short**\n%.2f", sig.ShortEMA),
+				Content: "**EMA Short**\n" + formatPrice(sig.ShortEMA),
 			},
 		})
 		fields = append(fields, FieldObject{
 			IsShort: true,
 			Text: TagText{
 				Tag:     "lark_md",
-				Content: fmt.Sprintf("**EMA Long**\n%.2f", sig.LongEMA),
+				Content: "**EMA Long**\n" + formatPrice(sig.LongEMA),
 			},
 		})
 	}
@@ -126,49 +174,254 @@ func (m *MessageCard) BuildLarkMessage(sig signal.Signal) LarkCard {
 			},
 		})
 	}
-    
-    // Buttons
-    var actions []ButtonObject
-    for _, btn := range m.Config.LarkSpecific.Buttons {
-        // Replace placeholders in URL
-        url := strings.ReplaceAll(btn.URL, "{symbol}", sig.Symbol)
-        
-        actions = append(actions, ButtonObject{
-            Tag: "button",
-            Text: TagText{
-                Tag: "plain_text",
-                Content: btn.Text,
-            },
-            Url: url,
-            Type: "primary",
-        })
-    }
-    
-    elements := []interface{}{
-        DivElement{
-            Tag: "div",
-            Fields: fields,
-        },
-        DivElement{
-            Tag: "hr",
-        },
-        ActionElement{
-            Tag: "action",
-            Actions: actions,
-        },
-    }
 
-	return LarkCard{
-		MsgType: "interactive",
-		Card: CardBody{
-			Header: CardHeader{
-				Template: template,
-				Title: TagText{
-					Tag:     "plain_text",
-					Content: titleText,
+	if sig.Score > 0 {
+		fields = append(fields, FieldObject{
+			IsShort: true,
+			Text: TagText{
+				Tag:     "lark_md",
+				Content: fmt.Sprintf("**信号强度**\n%.0f (%s)", sig.Score, sig.Severity),
+			},
+		})
+	}
+
+	if len(sig.Timeframes) > 0 {
+		trends := make([]string, 0, len(sig.Timeframes))
+		for _, tf := range sig.Timeframes {
+			trends = append(trends, fmt.Sprintf("%s %s", tf.Interval, trendArrow(tf.Trend)))
+		}
+		fields = append(fields, FieldObject{
+			IsShort: false,
+			Text: TagText{
+				Tag:     "lark_md",
+				Content: fmt.Sprintf("**多周期趋势**\n%s", strings.Join(trends, " / ")),
+			},
+		})
+	}
+
+	if sig.Risk != nil {
+		targets := make([]string, 0, len(sig.Risk.TakeProfits))
+		for _, tp := range sig.Risk.TakeProfits {
+			targets = append(targets, formatPrice(tp))
+		}
+		fields = append(fields,
+			FieldObject{
+				IsShort: true,
+				Text: TagText{
+					Tag:     "lark_md",
+					Content: "**建议止损**\n" + formatPrice(sig.Risk.StopLoss),
+				},
+			},
+			FieldObject{
+				IsShort: true,
+				Text: TagText{
+					Tag:     "lark_md",
+					Content: fmt.Sprintf("**建议止盈**\n%s", strings.Join(targets, " / ")),
 				},
 			},
-			Elements: elements,
+			FieldObject{
+				IsShort: true,
+				Text: TagText{
+					Tag:     "lark_md",
+					Content: fmt.Sprintf("**盈亏比**\n%.2f", sig.Risk.RiskReward),
+				},
+			},
+			FieldObject{
+				IsShort: true,
+				Text: TagText{
+					Tag:     "lark_md",
+					Content: fmt.Sprintf("**ATR (%s)**\n%s", sig.Risk.Method, formatPrice(sig.Risk.ATR)),
+				},
+			},
+		)
+	}
+
+	// Buttons
+	var actions []ButtonObject
+	for _, btn := range m.Config.LarkSpecific.Buttons {
+		button := ButtonObject{
+			Tag: "button",
+			Text: TagText{
+				Tag:     "plain_text",
+				Content: btn.Text,
+			},
+			Type: "primary",
+		}
+
+		switch btn.Action {
+		case "":
+			// Replace placeholders in URL
+			button.Url = strings.ReplaceAll(btn.URL, "{symbol}", sig.Symbol)
+		case ActionAck, ActionIgnore, ActionMute, ActionChart:
+			// Interactive buttons are answered by the callback endpoint
+			button.Value = map[string]interface{}{
+				"action":    btn.Action,
+				"signal_id": sig.ID(),
+				"symbol":    sig.Symbol,
+				"interval":  sig.Interval,
+			}
+			if btn.Action == ActionMute {
+				button.Type = "danger"
+				button.Value["hours"] = btn.MuteHours
+			} else if btn.Action == ActionChart {
+				button.Type = "default"
+			}
+		default:
+			continue
+		}
+
+		actions = append(actions, button)
+	}
+
+	elements := []interface{}{
+		DivElement{
+			Tag:    "div",
+			Fields: fields,
 		},
 	}
+	if mentions := m.mentions(sig); mentions != "" {
+		elements = append(elements, DivElement{
+			Tag: "div",
+			Text: TagText{
+				Tag:     "lark_md",
+				Content: mentions,
+			},
+		})
+	}
+	elements = append(elements, extra...)
+	elements = append(elements,
+		DivElement{
+			Tag: "hr",
+		},
+		ActionElement{
+			Tag:     "action",
+			Actions: actions,
+		},
+	)
+
+	return CardBody{
+		Header: CardHeader{
+			Template: template,
+			Title: TagText{
+				Tag:     "plain_text",
+				Content: titleText,
+			},
+		},
+		Elements: elements,
+	}
+}
+
+func trendArrow(t signal.Trend) string {
+	switch t {
+	case signal.TrendUp:
+		return "↑"
+	case signal.TrendDown:
+		return "↓"
+	}
+	return "→"
+}
+
+// mentions returns the <at> markup for everyone to notify about the signal:
+// the global at_all/at_users settings plus every matching mention rule.
+func (m *MessageCard) mentions(sig signal.Signal) string {
+	lark := m.Config.LarkSpecific
+	atAll := lark.AtAll
+	users := append([]string(nil), lark.AtUsers...)
+
+	for _, rule := range lark.Mentions {
+		// Unknown severities are rejected when the config is loaded
+		minSeverity, err := signal.ParseSeverity(rule.MinSeverity)
+		if err != nil || sig.Severity < minSeverity {
+			continue
+		}
+		if len(rule.Intervals) > 0 && !contains(rule.Intervals, sig.Interval) {
+			continue
+		}
+		if len(rule.Symbols) > 0 && !contains(rule.Symbols, strings.ToLower(sig.Symbol)) {
+			continue
+		}
+		atAll = atAll || rule.AtAll
+		users = append(users, rule.Users...)
+	}
+
+	var parts []string
+	if atAll {
+		parts = append(parts, "<at id=all></at>")
+	}
+	seen := make(map[string]bool)
+	for _, u := range users {
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		parts = append(parts, fmt.Sprintf("<at id=%s></at>", u))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatPrice prints a price with up to 8 significant digits and without
+// exponent notation, so 0.000012345 and 43210.5 both read naturally.
+func formatPrice(v float64) string {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 8, 64), 64)
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// severityTemplate returns the header template of a raised severity. Colors
+// keep the direction, so an urgent golden cross does not read as bearish;
+// cross None stands for a mix of both.
+func severityTemplate(cross indicator.CrossType, severity signal.Severity) (string, bool) {
+	templates := map[signal.Severity]map[indicator.CrossType]string{
+		signal.SeverityWarning:  {indicator.GoldenCross: "turquoise", indicator.DeathCross: "orange", indicator.None: "yellow"},
+		signal.SeverityCritical: {indicator.GoldenCross: "green", indicator.DeathCross: "carmine", indicator.None: "purple"},
+	}
+	t, ok := templates[severity][cross]
+	return t, ok
+}
+
+// larkColors are the header templates Lark supports, with their approximate RGB.
+var larkColors = map[string][3]int{
+	"blue":      {0x33, 0x70, 0xff},
+	"wathet":    {0x4d, 0xc8, 0xf3},
+	"turquoise": {0x2e, 0xbd, 0xaa},
+	"green":     {0x34, 0xc7, 0x24},
+	"yellow":    {0xff, 0xc6, 0x0a},
+	"orange":    {0xff, 0x88, 0x00},
+	"red":       {0xf5, 0x4a, 0x45},
+	"carmine":   {0xe1, 0x46, 0x8f},
+	"violet":    {0xb2, 0x4f, 0xd8},
+	"purple":    {0x7f, 0x3b, 0xf5},
+	"indigo":    {0x49, 0x54, 0xe6},
+	"grey":      {0x8f, 0x95, 0x9e},
+}
+
+// larkTemplate accepts a Lark template name or a hex color such as "0078D7"
+// and returns the closest Lark template.
+func larkTemplate(color string) string {
+	color = strings.ToLower(strings.TrimPrefix(color, "#"))
+	if _, ok := larkColors[color]; ok {
+		return color
+	}
+
+	var r, g, b int
+	if _, err := fmt.Sscanf(color, "%02x%02x%02x", &r, &g, &b); err != nil || len(color) != 6 {
+		return "blue"
+	}
+
+	best, bestDist := "blue", -1
+	for name, c := range larkColors {
+		dist := (r-c[0])*(r-c[0]) + (g-c[1])*(g-c[1]) + (b-c[2])*(b-c[2])
+		if bestDist < 0 || dist < bestDist || dist == bestDist && name < best {
+			best, bestDist = name, dist
+		}
+	}
+	return best
 }
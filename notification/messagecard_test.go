@@ -0,0 +1,22 @@
+package notification
+
+import "testing"
+
+func TestFormatPrice(t *testing.T) {
+	tests := []struct {
+		price float64
+		want  string
+	}{
+		{43210.5, "43210.5"},
+		{43210.123456789, "43210.123"},
+		{0.000012345, "0.000012345"},
+		{0.0000123456789, "0.000012345679"},
+		{1e-10, "0.0000000001"},
+		{100, "100"},
+	}
+	for _, tt := range tests {
+		if got := formatPrice(tt.price); got != tt.want {
+			t.Errorf("formatPrice(%v) = %s, want %s", tt.price, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fibo-monitor/paper"
+
+	"go.uber.org/zap"
+)
+
+// PaperReporter sends a daily summary of the paper trading account.
+type PaperReporter struct {
+	trader   *paper.Trader
+	location *time.Location
+	sender   *WebhookSender
+	stop     chan struct{}
+	once     sync.Once
+	logger   *zap.Logger
+}
+
+func NewPaperReporter(trader *paper.Trader, location *time.Location, sender *WebhookSender, logger *zap.Logger) *PaperReporter {
+	return &PaperReporter{
+		trader:   trader,
+		location: location,
+		sender:   sender,
+		stop:     make(chan struct{}),
+		logger:   logger,
+	}
+}
+
+// Start sends a summary every day at midnight in the reporter's location,
+// covering the trades closed since the previous one, until Stop is called.
+func (r *PaperReporter) Start() {
+	go func() {
+		since := r.sender.clock.Now()
+		for {
+			now := r.sender.clock.Now().In(r.location)
+			next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, r.location)
+			select {
+			case <-r.sender.clock.After(next.Sub(now)):
+			case <-r.stop:
+				return
+			}
+			summary := r.trader.Summary(since)
+			since = summary.Time
+			r.logger.Info("Sending paper trading summary",
+				zap.Float64("equity", summary.Equity),
+				zap.Int("trades", summary.Trades),
+			)
+			r.sender.SendCard(r.build(summary))
+		}
+	}()
+}
+
+// Stop ends the summaries started by Start.
+func (r *PaperReporter) Stop() {
+	r.once.Do(func() { close(r.stop) })
+}
+
+func (r *PaperReporter) build(s paper.Summary) CardBody {
+	winRate := "-"
+	if s.Trades > 0 {
+		winRate = fmt.Sprintf("%.0f%%", float64(s.Wins)/float64(s.Trades)*100)
+	}
+	elements := []interface{}{
+		DivElement{Tag: "div", Text: TagText{
+			Tag: "lark_md",
+			Content: fmt.Sprintf("**权益** %.2f (%+.2f%%)\n**已实现盈亏** %+.2f · **未实现盈亏** %+.2f · **手续费** %.2f",
+				s.Equity, (s.Equity/s.InitialBalance-1)*100, s.RealizedPnL, s.UnrealizedPnL, s.Fees),
+		}},
+		DivElement{Tag: "div", Text: TagText{
+			Tag:     "lark_md",
+			Content: fmt.Sprintf("**今日平仓** %d 笔 · 胜率 %s · 盈亏 %+.2f", s.Trades, winRate, s.PnL),
+		}},
+		DivElement{Tag: "hr"},
+	}
+
+	if len(s.Positions) == 0 {
+		elements = append(elements, DivElement{Tag: "div", Text: TagText{Tag: "lark_md", Content: "**持仓** 无"}})
+	} else {
+		elements = append(elements, tableRow([]string{"持仓", "方向", "开仓价", "现价", "未实现盈亏"}, true))
+		for _, p := range s.Positions {
+			side := "多"
+			if p.Side == "short" {
+				side = "空"
+			}
+			elements = append(elements, tableRow([]string{
+				fmt.Sprintf("%s %s", strings.ToUpper(p.Symbol), p.Interval),
+				side,
+				formatPrice(p.Entry),
+				formatPrice(p.Mark),
+				fmt.Sprintf("%+.2f", p.Unrealized),
+			}, false))
+		}
+	}
+
+	elements = append(elements,
+		DivElement{Tag: "hr"},
+		NoteElement{Tag: "note", Elements: []TagText{{
+			Tag: "lark_md",
+			Content: fmt.Sprintf("%s ~ %s · 模拟交易，非真实成交",
+				s.Since.In(r.location).Format("2006-01-02 15:04"), s.Time.In(r.location).Format("2006-01-02 15:04")),
+		}}},
+	)
+
+	template := "green"
+	if s.PnL < 0 {
+		template = "red"
+	}
+	return CardBody{
+		Header: CardHeader{
+			Template: template,
+			Title:    TagText{Tag: "plain_text", Content: "📒 模拟交易日报"},
+		},
+		Elements: elements,
+	}
+}
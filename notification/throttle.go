@@ -0,0 +1,139 @@
+package notification
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"fibo-monitor/config"
+	"fibo-monitor/signal"
+)
+
+// maxQueued bounds the signals a channel holds back for later delivery.
+const maxQueued = 200
+
+// maxQueuedCards bounds the prebuilt cards, such as summaries, a channel holds
+// back. Only the latest are kept; older summaries are superseded anyway.
+const maxQueuedCards = 10
+
+// channel is one webhook destination with the lowest severity it receives,
+// an optional rate limit and optional quiet hours.
+type channel struct {
+	name        string
+	url         string
+	minSeverity signal.Severity
+	limiter     *tokenBucket
+	quiet       *quietHours
+
+	mu sync.Mutex
+	// held are signals deferred by quiet hours
+	held []signal.Signal
+	// overflow are signals that found the rate limit exhausted
+	overflow []signal.Signal
+	// cards are prebuilt cards deferred by quiet hours or the rate limit
+	cards []CardBody
+}
+
+// tokenBucket allows bursts of up to capacity cards, refilled at rate per second.
+type tokenBucket struct {
+	capacity float64
+	rate     float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(cfg config.RateLimitConfig) *tokenBucket {
+	if cfg.PerMinute <= 0 {
+		return nil
+	}
+	burst := float64(cfg.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		capacity: burst,
+		rate:     cfg.PerMinute / 60,
+		tokens:   burst,
+	}
+}
+
+// allow takes a token if one is available.
+func (b *tokenBucket) allow(now time.Time) bool {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// quietHours is a daily window, in a given time zone, during which only
+// signals of at least minSeverity are delivered.
+type quietHours struct {
+	location    *time.Location
+	start       time.Duration // offset from local midnight
+	end         time.Duration
+	minSeverity signal.Severity
+}
+
+func newQuietHours(cfg config.QuietHoursConfig) (*quietHours, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	location := time.UTC
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		location = loc
+	}
+	start, err := parseClock(cfg.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(cfg.End)
+	if err != nil {
+		return nil, err
+	}
+	minSeverity, err := signal.ParseSeverity(cfg.MinSeverity)
+	if err != nil {
+		return nil, err
+	}
+	return &quietHours{location: location, start: start, end: end, minSeverity: minSeverity}, nil
+}
+
+// active reports whether t falls in the window. Windows may span midnight.
+func (q *quietHours) active(t time.Time) bool {
+	local := t.In(q.location)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	if q.start <= q.end {
+		return offset >= q.start && offset < q.end
+	}
+	return offset >= q.start || offset < q.end
+}
+
+// parseClock parses "HH:MM" into an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// appendBounded appends and drops the oldest entries beyond maxQueued.
+func appendBounded(queue []signal.Signal, sigs ...signal.Signal) ([]signal.Signal, int) {
+	queue = append(queue, sigs...)
+	dropped := len(queue) - maxQueued
+	if dropped <= 0 {
+		return queue, 0
+	}
+	return append(queue[:0], queue[dropped:]...), dropped
+}
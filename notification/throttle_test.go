@@ -0,0 +1,116 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+func TestTokenBucketRefill(t *testing.T) {
+	// 6 per minute is one token every 10 seconds, with a burst of 2
+	b := newTokenBucket(config.RateLimitConfig{PerMinute: 6, Burst: 2})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after time.Duration
+		want  bool
+	}{
+		{0, true},
+		{0, true},
+		{0, false},
+		{9 * time.Second, false},
+		{10 * time.Second, true},
+		{15 * time.Second, false},
+		// A long pause refills no more than the burst
+		{10 * time.Minute, true},
+		{10 * time.Minute, true},
+		{10 * time.Minute, false},
+	}
+	for i, s := range steps {
+		if got := b.allow(start.Add(s.after)); got != s.want {
+			t.Errorf("step %d at +%v: allow = %v, want %v", i, s.after, got, s.want)
+		}
+	}
+}
+
+func TestTokenBucketDisabled(t *testing.T) {
+	if b := newTokenBucket(config.RateLimitConfig{}); b != nil {
+		t.Errorf("newTokenBucket without a rate = %+v, want nil", b)
+	}
+}
+
+func TestQuietHoursBoundary(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		at         string
+		want       bool
+	}{
+		{"at start", "22:00", "07:00", "22:00:00", true},
+		{"just before start", "22:00", "07:00", "21:59:59", false},
+		{"after midnight", "22:00", "07:00", "03:00:00", true},
+		{"just before end", "22:00", "07:00", "06:59:59", true},
+		{"at end", "22:00", "07:00", "07:00:00", false},
+		{"same day at start", "12:00", "13:00", "12:00:00", true},
+		{"same day at end", "12:00", "13:00", "13:00:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := newQuietHours(config.QuietHoursConfig{Enabled: true, Start: tt.start, End: tt.end, MinSeverity: "critical"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			at, err := time.Parse("2006-01-02 15:04:05", "2024-01-01 "+tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := q.active(at); got != tt.want {
+				t.Errorf("active(%s) in %s-%s = %v, want %v", tt.at, tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietHoursTimezone(t *testing.T) {
+	q, err := newQuietHours(config.QuietHoursConfig{Enabled: true, Timezone: "Asia/Shanghai", Start: "22:00", End: "07:00", MinSeverity: "critical"})
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	// 14:00 UTC is 22:00 in Shanghai
+	if !q.active(time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)) {
+		t.Error("quiet hours not active at 22:00 local time")
+	}
+	if q.active(time.Date(2024, 1, 1, 13, 59, 0, 0, time.UTC)) {
+		t.Error("quiet hours active at 21:59 local time")
+	}
+}
+
+func TestUpdateKeepsQueuedCards(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Enabled:    true,
+		URL:        "http://127.0.0.1:1/hook",
+		QuietHours: config.QuietHoursConfig{Enabled: true, Start: "00:00", End: "23:59", MinSeverity: "critical"},
+	}
+	clk := clock.NewSimulated(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	w := NewWebhookSender(cfg, config.MessageCardConfig{}, clk, zap.NewNop())
+	defer w.Stop()
+
+	w.Send(signal.Signal{Symbol: "BTCUSDT", Severity: signal.SeverityInfo})
+	w.SendCard(CardBody{})
+
+	w.Update(cfg, config.MessageCardConfig{})
+
+	_, channels := w.current()
+	ch := channels[0]
+	ch.mu.Lock()
+	held, cards := len(ch.held), len(ch.cards)
+	ch.mu.Unlock()
+	if held != 1 || cards != 1 {
+		t.Errorf("after Update: %d held, %d cards, want 1 and 1", held, cards)
+	}
+}
@@ -5,78 +5,465 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"fibo-monitor/clock"
 	"fibo-monitor/config"
 	"fibo-monitor/signal"
 
 	"go.uber.org/zap"
 )
 
+// sentCardHistory is the number of sent signals whose cards can still be
+// updated by button callbacks.
+const sentCardHistory = 500
+
 type WebhookSender struct {
 	config      config.WebhookConfig
+	channels    []*channel
 	cardBuilder *MessageCard
 	client      *http.Client
-	logger      *zap.Logger
+	// mu guards the fields above, which Update replaces on config reload
+	mu sync.RWMutex
+	// dryRun logs cards instead of posting them
+	dryRun    atomic.Bool
+	drainOnce sync.Once
+	// inflight counts deliveries started by deliver
+	inflight  sync.WaitGroup
+	// draining tracks drainLoop so Stop can wait for its last pass
+	draining  sync.WaitGroup
+	stop      chan struct{}
+	stopOnce  sync.Once
+	cards     *CardRegistry
+	clock     clock.Clock
+	logger    *zap.Logger
+
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// DeliveryStats counts webhook deliveries, one per card per channel.
+type DeliveryStats struct {
+	Delivered uint64 `json:"delivered"`
+	Failed    uint64 `json:"failed"`
 }
 
-func NewWebhookSender(cfg config.WebhookConfig, cardCfg config.MessageCardConfig, logger *zap.Logger) *WebhookSender {
-	return &WebhookSender{
+func NewWebhookSender(cfg config.WebhookConfig, cardCfg config.MessageCardConfig, clk clock.Clock, logger *zap.Logger) *WebhookSender {
+	w := &WebhookSender{
 		config:      cfg,
+		channels:    buildChannels(cfg, logger),
 		cardBuilder: NewMessageCard(cardCfg),
+		cards:       NewCardRegistry(sentCardHistory),
 		client: &http.Client{
 			Timeout: cfg.Timeout,
 		},
+		stop:   make(chan struct{}),
+		clock:  clk,
 		logger: logger,
 	}
+
+	w.startDrain()
+	return w
+}
+
+// Update applies a reloaded webhook and card configuration. Signals queued
+// by rate limits or quiet hours move to the channel of the same name.
+func (w *WebhookSender) Update(cfg config.WebhookConfig, cardCfg config.MessageCardConfig) {
+	channels := buildChannels(cfg, w.logger)
+
+	w.mu.Lock()
+	for _, old := range w.channels {
+		for _, ch := range channels {
+			if ch.name == old.name {
+				old.mu.Lock()
+				ch.held, ch.overflow, ch.cards = old.held, old.overflow, old.cards
+				old.held, old.overflow, old.cards = nil, nil, nil
+				old.mu.Unlock()
+			}
+		}
+	}
+	w.config = cfg
+	w.channels = channels
+	w.cardBuilder = NewMessageCard(cardCfg)
+	w.client = &http.Client{Timeout: cfg.Timeout}
+	w.mu.Unlock()
+
+	w.startDrain()
+}
+
+// SetDryRun makes the sender log cards instead of delivering them.
+func (w *WebhookSender) SetDryRun(enabled bool) {
+	w.dryRun.Store(enabled)
+}
+
+// DeliverCard posts a card to every channel accepting the severity and waits
+// for the result, ignoring rate limits and quiet hours. It is meant for
+// checking the webhook setup.
+func (w *WebhookSender) DeliverCard(card CardBody, severity signal.Severity) error {
+	_, channels := w.current()
+	var failed []string
+	delivered := 0
+	for _, ch := range channels {
+		if severity < ch.minSeverity {
+			continue
+		}
+		if !w.sendCard(ch, card) {
+			failed = append(failed, ch.name)
+			continue
+		}
+		delivered++
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("delivery failed for channels: %s", strings.Join(failed, ", "))
+	}
+	if delivered == 0 {
+		return fmt.Errorf("no channel accepts %s signals", severity)
+	}
+	return nil
+}
+
+// deliver sends a card in the background, tracked so Stop can wait for it.
+func (w *WebhookSender) deliver(ch *channel, card CardBody) {
+	w.inflight.Add(1)
+	go func() {
+		defer w.inflight.Done()
+		w.sendCard(ch, card)
+	}()
+}
+
+// Stop ends drainLoop and waits for the deliveries in progress, including
+// their retries. Signals and cards still queued by quiet hours or rate limits
+// are not sent; they are logged per channel as lost.
+func (w *WebhookSender) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	w.draining.Wait()
+	w.inflight.Wait()
+
+	_, channels := w.current()
+	for _, ch := range channels {
+		ch.mu.Lock()
+		held, overflow, cards := len(ch.held), len(ch.overflow), len(ch.cards)
+		ch.held, ch.overflow, ch.cards = nil, nil, nil
+		ch.mu.Unlock()
+		if held+overflow+cards > 0 {
+			w.logger.Warn("Queued notifications lost on shutdown",
+				zap.String("channel", ch.name),
+				zap.Int("held", held),
+				zap.Int("overflow", overflow),
+				zap.Int("cards", cards),
+			)
+		}
+	}
+}
+
+// startDrain starts drainLoop once any channel has limits or quiet hours.
+func (w *WebhookSender) startDrain() {
+	_, channels := w.current()
+	for _, ch := range channels {
+		if ch.limiter != nil || ch.quiet != nil {
+			w.drainOnce.Do(func() {
+				w.draining.Add(1)
+				go w.drainLoop()
+			})
+			return
+		}
+	}
+}
+
+func (w *WebhookSender) current() (config.WebhookConfig, []*channel) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.config, w.channels
+}
+
+func (w *WebhookSender) messageCard() *MessageCard {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.cardBuilder
+}
+
+func buildChannels(cfg config.WebhookConfig, logger *zap.Logger) []*channel {
+	all := append([]config.ChannelConfig{{
+		Name:        "default",
+		URL:         cfg.URL,
+		Secret:      cfg.Secret,
+		MinSeverity: cfg.MinSeverity,
+		RateLimit:   cfg.RateLimit,
+		QuietHours:  cfg.QuietHours,
+	}}, cfg.Channels...)
+
+	var channels []*channel
+	for _, c := range all {
+		if c.URL == "" {
+			continue
+		}
+		severity, err := signal.ParseSeverity(c.MinSeverity)
+		if err != nil {
+			logger.Warn("Invalid channel severity, using info", zap.String("channel", c.Name), zap.Error(err))
+		}
+		quiet, err := newQuietHours(c.QuietHours)
+		if err != nil {
+			logger.Warn("Invalid quiet hours, disabled", zap.String("channel", c.Name), zap.Error(err))
+		}
+		channels = append(channels, &channel{
+			name:        c.Name,
+			url:         c.URL,
+			minSeverity: severity,
+			limiter:     newTokenBucket(c.RateLimit),
+			quiet:       quiet,
+		})
+	}
+	return channels
 }
 
 func (w *WebhookSender) Send(sig signal.Signal) {
-	if !w.config.Enabled || w.config.URL == "" {
+	cfg, channels := w.current()
+	if !cfg.Enabled {
 		return
 	}
 
+	w.cards.Add(sig)
+
+	// Route to every channel accepting the signal's severity.
 	// Always send using Lark format as it's the only one supported now
-	go w.sendLark(sig)
+	for _, ch := range channels {
+		if sig.Severity >= ch.minSeverity {
+			w.dispatch(ch, []signal.Signal{sig})
+		}
+	}
 }
 
-func (w *WebhookSender) sendLark(sig signal.Signal) {
-	msg := w.cardBuilder.BuildLarkMessage(sig)
+// SendDigest delivers several signals at once. Each channel receives the
+// signals matching its severity, as a single card if only one matches.
+func (w *WebhookSender) SendDigest(sigs []signal.Signal) {
+	cfg, channels := w.current()
+	if !cfg.Enabled {
+		return
+	}
+
+	for _, sig := range sigs {
+		w.cards.Add(sig)
+	}
+
+	for _, ch := range channels {
+		var matched []signal.Signal
+		for _, sig := range sigs {
+			if sig.Severity >= ch.minSeverity {
+				matched = append(matched, sig)
+			}
+		}
+		if len(matched) > 0 {
+			w.dispatch(ch, matched)
+		}
+	}
+}
+
+// SendCard delivers a prebuilt card, such as a summary, to the channels
+// that receive routine (info) signals. Cards arriving during quiet hours or
+// with the channel's rate limit exhausted are delivered later by drainLoop.
+func (w *WebhookSender) SendCard(card CardBody) {
+	cfg, channels := w.current()
+	if !cfg.Enabled {
+		return
+	}
+
+	now := w.clock.Now()
+	for _, ch := range channels {
+		if ch.minSeverity != signal.SeverityInfo {
+			continue
+		}
+		ch.mu.Lock()
+		ok := len(ch.cards) == 0 && (ch.quiet == nil || !ch.quiet.active(now)) && (ch.limiter == nil || ch.limiter.allow(now))
+		if !ok {
+			ch.cards = append(ch.cards, card)
+			if dropped := len(ch.cards) - maxQueuedCards; dropped > 0 {
+				ch.cards = append(ch.cards[:0], ch.cards[dropped:]...)
+				w.logger.Warn("Queued cards dropped", zap.String("channel", ch.name), zap.Int("dropped", dropped))
+			}
+		}
+		ch.mu.Unlock()
+		if !ok {
+			w.logger.Info("Card deferred by quiet hours or rate limit", zap.String("channel", ch.name))
+			continue
+		}
+		w.deliver(ch, card)
+	}
+}
+
+// dispatch applies the channel's quiet hours and rate limit. Signals held back
+// are delivered later as a digest by drainLoop.
+func (w *WebhookSender) dispatch(ch *channel, sigs []signal.Signal) {
+	now := w.clock.Now()
+	ch.mu.Lock()
+
+	if ch.quiet != nil && ch.quiet.active(now) {
+		var urgent []signal.Signal
+		for _, sig := range sigs {
+			if sig.Severity >= ch.quiet.minSeverity {
+				urgent = append(urgent, sig)
+			} else {
+				var dropped int
+				ch.held, dropped = appendBounded(ch.held, sig)
+				w.logDropped(ch, dropped)
+			}
+		}
+		sigs = urgent
+	}
+	if len(sigs) == 0 {
+		ch.mu.Unlock()
+		return
+	}
+
+	if ch.limiter != nil && !ch.limiter.allow(now) {
+		var dropped int
+		ch.overflow, dropped = appendBounded(ch.overflow, sigs...)
+		w.logDropped(ch, dropped)
+		ch.mu.Unlock()
+		w.logger.Info("Channel rate limited, signals folded into next digest",
+			zap.String("channel", ch.name),
+			zap.Int("queued", len(ch.overflow)),
+		)
+		return
+	}
+	ch.mu.Unlock()
+
+	w.deliver(ch, w.cardFor(sigs))
+}
+
+// drainLoop releases held signals once quiet hours end and sends rate
+// limited overflow as a digest whenever a token is available, followed by
+// deferred cards.
+func (w *WebhookSender) drainLoop() {
+	defer w.draining.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		now := w.clock.Now()
+		_, channels := w.current()
+		for _, ch := range channels {
+			ch.mu.Lock()
+			if len(ch.held) > 0 && (ch.quiet == nil || !ch.quiet.active(now)) {
+				var dropped int
+				ch.overflow, dropped = appendBounded(ch.held, ch.overflow...)
+				w.logDropped(ch, dropped)
+				ch.held = nil
+			}
+			var sigs []signal.Signal
+			if len(ch.overflow) > 0 && (ch.limiter == nil || ch.limiter.allow(now)) {
+				sigs = ch.overflow
+				ch.overflow = nil
+			}
+			var cards []CardBody
+			if ch.quiet == nil || !ch.quiet.active(now) {
+				for len(ch.cards) > 0 && (ch.limiter == nil || ch.limiter.allow(now)) {
+					cards = append(cards, ch.cards[0])
+					ch.cards = ch.cards[1:]
+				}
+			}
+			ch.mu.Unlock()
+
+			if len(sigs) > 0 {
+				w.logger.Info("Delivering queued signals", zap.String("channel", ch.name), zap.Int("signals", len(sigs)))
+				w.deliver(ch, w.cardFor(sigs))
+			}
+			for _, card := range cards {
+				w.logger.Info("Delivering queued card", zap.String("channel", ch.name))
+				w.deliver(ch, card)
+			}
+		}
+	}
+}
+
+func (w *WebhookSender) cardFor(sigs []signal.Signal) CardBody {
+	if len(sigs) == 1 {
+		return w.messageCard().BuildCard(sigs[0])
+	}
+	return w.messageCard().BuildDigestCard(sigs)
+}
+
+func (w *WebhookSender) logDropped(ch *channel, dropped int) {
+	if dropped > 0 {
+		w.logger.Warn("Channel queue full, oldest signals dropped", zap.String("channel", ch.name), zap.Int("dropped", dropped))
+	}
+}
+
+// Stats returns the delivery counters since startup.
+func (w *WebhookSender) Stats() DeliveryStats {
+	return DeliveryStats{
+		Delivered: w.delivered.Load(),
+		Failed:    w.failed.Load(),
+	}
+}
+
+// sendCard posts a card to a channel and reports whether it was delivered.
+func (w *WebhookSender) sendCard(ch *channel, card CardBody) bool {
+	msg := LarkCard{
+		MsgType: "interactive",
+		Card:    card,
+	}
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		w.logger.Error("Failed to marshal lark message", zap.Error(err))
-		return
+		return false
+	}
+
+	if w.dryRun.Load() {
+		w.logger.Info("Dry run, card not sent",
+			zap.String("channel", ch.name),
+			zap.String("title", card.Header.Title.Content),
+			zap.ByteString("payload", payload),
+		)
+		return true
 	}
 
-	// TODO: Add signature handling if w.config.Secret is set
+	// TODO: Add signature handling if the channel secret is set
 	// For now, simple POST
-	w.performRequest(w.config.URL, payload)
+	if w.performRequest(ch.url, payload) {
+		w.delivered.Add(1)
+		return true
+	}
+	w.failed.Add(1)
+	return false
 }
 
-func (w *WebhookSender) performRequest(url string, payload []byte) {
-	for i := 0; i <= w.config.RetryCount; i++ {
+func (w *WebhookSender) performRequest(url string, payload []byte) bool {
+	w.mu.RLock()
+	cfg, client := w.config, w.client
+	w.mu.RUnlock()
+
+	for i := 0; i <= cfg.RetryCount; i++ {
 		req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
 		if err != nil {
 			w.logger.Error("Failed to create request", zap.Error(err))
-			return
+			return false
 		}
 		req.Header.Set("Content-Type", "application/json")
 
-		resp, err := w.client.Do(req)
+		resp, err := client.Do(req)
 		if err == nil {
 			defer resp.Body.Close()
 			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 				w.logger.Info("Webhook sent successfully")
-				return
+				return true
 			}
 			err = fmt.Errorf("status code: %d", resp.StatusCode)
 		}
 
 		w.logger.Warn("Webhook failed", zap.Error(err), zap.Int("attempt", i+1))
 		
-		if i < w.config.RetryCount {
-			time.Sleep(w.config.RetryBackoff)
+		if i < cfg.RetryCount {
+			time.Sleep(cfg.RetryBackoff)
 		}
 	}
 	w.logger.Error("Webhook failed after retries")
+	return false
 }
\ No newline at end of file
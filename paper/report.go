@@ -0,0 +1,87 @@
+package paper
+
+import (
+	"sort"
+	"time"
+)
+
+// Summary is the state of the paper account.
+type Summary struct {
+	Time           time.Time  `json:"time"`
+	InitialBalance float64    `json:"initial_balance"`
+	Balance        float64    `json:"balance"` // initial balance plus realized PnL
+	Equity         float64    `json:"equity"`  // balance plus unrealized PnL
+	RealizedPnL    float64    `json:"realized_pnl"`
+	UnrealizedPnL  float64    `json:"unrealized_pnl"`
+	Fees           float64    `json:"fees"`
+	Positions      []Position `json:"positions"`
+	// Trades, Wins and PnL cover the trades closed since the period start
+	Since  time.Time `json:"since"`
+	Trades int       `json:"trades"`
+	Wins   int       `json:"wins"`
+	PnL    float64   `json:"pnl"`
+}
+
+// Summary reports the account, with trade statistics for trades closed
+// since the given time (all kept trades for the zero time).
+func (t *Trader) Summary(since time.Time) Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := Summary{
+		Time:           t.clock.Now(),
+		InitialBalance: t.config.InitialBalance,
+		Balance:        t.balance,
+		Equity:         t.equityLocked(),
+		RealizedPnL:    t.balance - t.config.InitialBalance,
+		Fees:           t.fees,
+		Positions:      t.positionsLocked(),
+		Since:          since,
+	}
+	for _, p := range s.Positions {
+		s.UnrealizedPnL += p.Unrealized
+	}
+	for _, trade := range t.trades {
+		if trade.ClosedAt.Before(since) {
+			continue
+		}
+		s.Trades++
+		s.PnL += trade.PnL
+		if trade.PnL > 0 {
+			s.Wins++
+		}
+	}
+	return s
+}
+
+// Positions returns the open positions sorted by symbol.
+func (t *Trader) Positions() []Position {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.positionsLocked()
+}
+
+func (t *Trader) positionsLocked() []Position {
+	positions := make([]Position, 0, len(t.positions))
+	for _, p := range t.positions {
+		pos := p.Position
+		pos.TakeProfits = append([]float64(nil), p.TakeProfits...)
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return positions
+}
+
+// Equity returns the equity curve, oldest first.
+func (t *Trader) Equity() []EquityPoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]EquityPoint(nil), t.equity...)
+}
+
+// Trades returns the most recent closed trades, oldest first.
+func (t *Trader) Trades() []Trade {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Trade(nil), t.trades...)
+}
@@ -0,0 +1,366 @@
+// Package paper simulates trading the signals the pipeline emits, so a
+// strategy can be judged by its PnL before real money follows it.
+package paper
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+	"fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+// maxTrades closed trades are kept for the API.
+const maxTrades = 1000
+
+// Position is an open simulated position.
+type Position struct {
+	Symbol   string  `json:"symbol"`
+	Interval string  `json:"interval"` // of the signal that opened it
+	Side     string  `json:"side"`     // long or short
+	Entry    float64 `json:"entry"`
+	// Quantity is what is still open; targets close it in equal parts of
+	// InitialQuantity
+	Quantity        float64   `json:"quantity"`
+	InitialQuantity float64   `json:"initial_quantity"`
+	StopLoss        float64   `json:"stop_loss,omitempty"`
+	TakeProfits     []float64 `json:"take_profits,omitempty"` // targets not reached yet
+	OpenedAt        time.Time `json:"opened_at"`
+	SignalID        string    `json:"signal_id"`
+	Mark            float64   `json:"mark"`
+	Unrealized      float64   `json:"unrealized_pnl"`
+	// Realized is the net result of partial exits so far, fees included
+	Realized float64 `json:"realized_pnl"`
+	Fees     float64 `json:"fees"`
+}
+
+// Trade is a closed position.
+type Trade struct {
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	Side     string    `json:"side"`
+	Entry    float64   `json:"entry"`
+	Exit     float64   `json:"exit"` // average over all exits
+	Quantity float64   `json:"quantity"`
+	PnL      float64   `json:"pnl"` // net of fees
+	Fees     float64   `json:"fees"`
+	Reason   string    `json:"reason"` // stop, target or opposite
+	OpenedAt time.Time `json:"opened_at"`
+	ClosedAt time.Time `json:"closed_at"`
+}
+
+// EquityPoint is the account value at a point in time.
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+}
+
+// Trader opens simulated positions from signals and marks them to market on
+// every kline update. Each symbol holds at most one position.
+type Trader struct {
+	config config.PaperConfig
+	clock  clock.Clock
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	balance   float64 // initial balance plus realized PnL
+	fees      float64
+	positions map[string]*position
+	trades    []Trade
+	equity    []EquityPoint
+	lastPoint time.Time
+}
+
+type position struct {
+	Position
+	direction float64 // +1 long, -1 short
+	targets   int     // number of targets at entry
+	exitValue float64 // sum of exit price × quantity, for the average exit
+}
+
+func NewTrader(cfg config.PaperConfig, clk clock.Clock, logger *zap.Logger) *Trader {
+	return &Trader{
+		config:    cfg,
+		clock:     clk,
+		logger:    logger,
+		balance:   cfg.InitialBalance,
+		positions: make(map[string]*position),
+	}
+}
+
+// Track marks open positions to market and triggers stops and targets. Klines
+// pass through unchanged.
+func (t *Trader) Track(inChan <-chan kline.KlineEvent) <-chan kline.KlineEvent {
+	outChan := make(chan kline.KlineEvent, 100)
+
+	go func() {
+		defer close(outChan)
+		for event := range inChan {
+			t.mark(event)
+			outChan <- event
+		}
+	}()
+
+	return outChan
+}
+
+// Run trades on signals, which pass through unchanged.
+func (t *Trader) Run(inChan <-chan signal.Signal) <-chan signal.Signal {
+	outChan := make(chan signal.Signal, 100)
+
+	go func() {
+		defer close(outChan)
+		for sig := range inChan {
+			t.trade(sig)
+			outChan <- sig
+		}
+	}()
+
+	return outChan
+}
+
+func (t *Trader) trade(sig signal.Signal) {
+	if len(t.config.Intervals) > 0 && !contains(t.config.Intervals, sig.Interval) {
+		return
+	}
+	direction := 1.0
+	if sig.Type == indicator.DeathCross {
+		direction = -1
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.positions[sig.Symbol]; ok {
+		if p.direction == direction || !t.config.ExitOnOpposite {
+			return
+		}
+		t.exit(p, p.Quantity, t.fill(sig.Price, -p.direction), "opposite", sig.Time())
+	}
+	t.open(sig, direction)
+	t.record(sig.Time(), true)
+}
+
+// open starts a position. Callers must hold t.mu.
+func (t *Trader) open(sig signal.Signal, direction float64) {
+	entry := t.fill(sig.Price, direction)
+	var stop float64
+	var targets []float64
+	if sig.Risk != nil {
+		stop = sig.Risk.StopLoss
+		targets = append(targets, sig.Risk.TakeProfits...)
+	}
+
+	quantity := t.size(entry, stop)
+	if quantity <= 0 {
+		t.logger.Debug("Paper position not opened",
+			zap.String("symbol", sig.Symbol),
+			zap.String("sizing", t.config.Sizing),
+		)
+		return
+	}
+	fee := entry * quantity * t.config.FeePct / 100
+	t.balance -= fee
+	t.fees += fee
+
+	side := "long"
+	if direction < 0 {
+		side = "short"
+	}
+	p := &position{
+		Position: Position{
+			Symbol:          sig.Symbol,
+			Interval:        sig.Interval,
+			Side:            side,
+			Entry:           entry,
+			Quantity:        quantity,
+			InitialQuantity: quantity,
+			StopLoss:        stop,
+			TakeProfits:     targets,
+			OpenedAt:        sig.Time(),
+			SignalID:        sig.ID(),
+			Mark:            sig.Price,
+			Realized:        -fee,
+			Fees:            fee,
+		},
+		direction: direction,
+		targets:   len(targets),
+	}
+	p.Unrealized = (p.Mark - entry) * direction * quantity
+	t.positions[sig.Symbol] = p
+
+	t.logger.Info("Paper position opened",
+		zap.String("symbol", sig.Symbol),
+		zap.String("side", side),
+		zap.Float64("entry", entry),
+		zap.Float64("quantity", quantity),
+		zap.Float64("stop_loss", stop),
+	)
+}
+
+// size returns the quantity to trade at entry, capped by max_leverage.
+func (t *Trader) size(entry, stop float64) float64 {
+	equity := t.equityLocked()
+	if equity <= 0 || entry <= 0 {
+		return 0
+	}
+	var notional float64
+	switch t.config.Sizing {
+	case "notional":
+		notional = t.config.Size
+	case "equity_pct":
+		notional = equity * t.config.Size / 100
+	case "risk_pct":
+		// Lose Size% of equity at the stop
+		if stop <= 0 || stop == entry {
+			return 0
+		}
+		notional = equity * t.config.Size / 100 / math.Abs(entry-stop) * entry
+	}
+	if t.config.MaxLeverage > 0 {
+		notional = math.Min(notional, equity*t.config.MaxLeverage)
+	}
+	return notional / entry
+}
+
+// fill applies slippage against a market order buying (direction +1) or
+// selling (-1) at price.
+func (t *Trader) fill(price, direction float64) float64 {
+	return price * (1 + direction*t.config.SlippagePct/100)
+}
+
+// exit closes quantity of a position at price and time at. Callers must hold
+// t.mu.
+func (t *Trader) exit(p *position, quantity, price float64, reason string, at time.Time) {
+	quantity = math.Min(quantity, p.Quantity)
+	fee := price * quantity * t.config.FeePct / 100
+	pnl := (price-p.Entry)*p.direction*quantity - fee
+	t.balance += pnl
+	t.fees += fee
+	p.Realized += pnl
+	p.Fees += fee
+	p.Quantity -= quantity
+	p.exitValue += price * quantity
+
+	// Remainders from floating point are closed with the position
+	if p.Quantity > p.InitialQuantity*1e-9 {
+		t.logger.Info("Paper position reduced",
+			zap.String("symbol", p.Symbol),
+			zap.String("reason", reason),
+			zap.Float64("price", price),
+			zap.Float64("remaining", p.Quantity),
+		)
+		return
+	}
+
+	delete(t.positions, p.Symbol)
+	trade := Trade{
+		Symbol:   p.Symbol,
+		Interval: p.Interval,
+		Side:     p.Side,
+		Entry:    p.Entry,
+		Exit:     p.exitValue / p.InitialQuantity,
+		Quantity: p.InitialQuantity,
+		PnL:      p.Realized,
+		Fees:     p.Fees,
+		Reason:   reason,
+		OpenedAt: p.OpenedAt,
+		ClosedAt: at,
+	}
+	t.trades = append(t.trades, trade)
+	if len(t.trades) > maxTrades {
+		t.trades = t.trades[len(t.trades)-maxTrades:]
+	}
+	t.logger.Info("Paper position closed",
+		zap.String("symbol", trade.Symbol),
+		zap.String("side", trade.Side),
+		zap.String("reason", reason),
+		zap.Float64("exit", trade.Exit),
+		zap.Float64("pnl", trade.PnL),
+	)
+}
+
+func (t *Trader) mark(event kline.KlineEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Marking runs ahead of the detector, so it goes by the event time
+	// rather than the clock
+	at := time.UnixMilli(event.Time)
+	p, ok := t.positions[event.Symbol]
+	if !ok {
+		t.record(at, false)
+		return
+	}
+
+	// The range of a candle that began before the entry may predate it
+	c := event.Candle
+	low, high := c.Close, c.Close
+	if !c.StartTime.Before(p.OpenedAt) {
+		low, high = c.Low, c.High
+	}
+	adverse, favorable := low, high
+	if p.direction < 0 {
+		adverse, favorable = high, low
+	}
+
+	// A candle reaching both is assumed to have hit the stop first
+	if p.StopLoss > 0 && (adverse-p.StopLoss)*p.direction <= 0 {
+		t.exit(p, p.Quantity, t.fill(p.StopLoss, -p.direction), "stop", at)
+		t.record(at, true)
+		return
+	}
+	for len(p.TakeProfits) > 0 && (favorable-p.TakeProfits[0])*p.direction >= 0 {
+		target := p.TakeProfits[0]
+		p.TakeProfits = p.TakeProfits[1:]
+		quantity := p.InitialQuantity / float64(p.targets)
+		if len(p.TakeProfits) == 0 {
+			quantity = p.Quantity
+		}
+		t.exit(p, quantity, target, "target", at)
+		if _, open := t.positions[p.Symbol]; !open {
+			t.record(at, true)
+			return
+		}
+	}
+
+	p.Mark = c.Close
+	p.Unrealized = (p.Mark - p.Entry) * p.direction * p.Quantity
+	t.record(at, false)
+}
+
+// record adds a point at now to the equity curve every equity_interval, or
+// whenever forced. Callers must hold t.mu.
+func (t *Trader) record(now time.Time, force bool) {
+	if !force && now.Sub(t.lastPoint) < t.config.EquityInterval {
+		return
+	}
+	t.lastPoint = now
+	t.equity = append(t.equity, EquityPoint{Time: now, Equity: t.equityLocked()})
+	if max := t.config.EquityPoints; max > 0 && len(t.equity) > max {
+		t.equity = t.equity[len(t.equity)-max:]
+	}
+}
+
+func (t *Trader) equityLocked() float64 {
+	equity := t.balance
+	for _, p := range t.positions {
+		equity += p.Unrealized
+	}
+	return equity
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package paper
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/config"
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+	"fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// bar is the range of an hourly candle; drive opens the i-th one i+1 hours
+// after start.
+type bar struct {
+	low, high, close float64
+}
+
+func newTestTrader(cfg config.PaperConfig) *Trader {
+	if cfg.InitialBalance == 0 {
+		cfg.InitialBalance = 10000
+	}
+	if cfg.Sizing == "" {
+		cfg.Sizing, cfg.Size = "notional", 1000
+	}
+	return NewTrader(cfg, clock.NewSimulated(start), zap.NewNop())
+}
+
+func crossAt(cross indicator.CrossType, price, stop float64, targets ...float64) signal.Signal {
+	sig := signal.Signal{Type: cross, Symbol: "BTCUSDT", Interval: "1h", Price: price, Timestamp: start, EventTime: start}
+	if stop > 0 || len(targets) > 0 {
+		sig.Risk = &signal.RiskPlan{StopLoss: stop, TakeProfits: targets}
+	}
+	return sig
+}
+
+// drive trades the signal and then marks the bars, in pipeline order.
+func drive(tr *Trader, sig signal.Signal, bars []bar) {
+	sigs := make(chan signal.Signal, 1)
+	sigs <- sig
+	close(sigs)
+	for range tr.Run(sigs) {
+	}
+
+	events := make(chan kline.KlineEvent, len(bars))
+	for i, b := range bars {
+		open := start.Add(time.Duration(i+1) * time.Hour)
+		events <- kline.KlineEvent{
+			Symbol: sig.Symbol,
+			Time:   open.Add(time.Hour - time.Millisecond).UnixMilli(),
+			Candle: kline.Candle{
+				Symbol:    sig.Symbol,
+				Interval:  sig.Interval,
+				StartTime: open,
+				CloseTime: open.Add(time.Hour - time.Millisecond),
+				Open:      b.close,
+				Low:       b.low,
+				High:      b.high,
+				Close:     b.close,
+				IsClosed:  true,
+			},
+		}
+	}
+	close(events)
+	for range tr.Track(events) {
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestTraderExits(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.PaperConfig
+		sig    signal.Signal
+		bars   []bar
+		reason string // empty when the position stays open
+		exit   float64
+		pnl    float64
+	}{
+		{
+			name:   "long stop",
+			sig:    crossAt(indicator.GoldenCross, 100, 95, 110),
+			bars:   []bar{{99, 101, 100}, {94, 100, 96}},
+			reason: "stop", exit: 95, pnl: -50,
+		},
+		{
+			name:   "long target",
+			sig:    crossAt(indicator.GoldenCross, 100, 95, 110),
+			bars:   []bar{{99, 111, 108}},
+			reason: "target", exit: 110, pnl: 100,
+		},
+		{
+			name:   "short target",
+			sig:    crossAt(indicator.DeathCross, 100, 105, 90),
+			bars:   []bar{{89, 101, 92}},
+			reason: "target", exit: 90, pnl: 100,
+		},
+		{
+			name:   "short stop",
+			sig:    crossAt(indicator.DeathCross, 100, 105, 90),
+			bars:   []bar{{99, 106, 104}},
+			reason: "stop", exit: 105, pnl: -50,
+		},
+		{
+			name:   "both levels in one candle count as the stop",
+			sig:    crossAt(indicator.GoldenCross, 100, 95, 110),
+			bars:   []bar{{94, 111, 100}},
+			reason: "stop", exit: 95, pnl: -50,
+		},
+		{
+			name:   "targets close equal parts",
+			sig:    crossAt(indicator.GoldenCross, 100, 95, 105, 110),
+			bars:   []bar{{100, 106, 105}, {104, 111, 110}},
+			reason: "target", exit: 107.5, pnl: 75,
+		},
+		{
+			name: "fees and slippage",
+			cfg:  config.PaperConfig{FeePct: 0.1, SlippagePct: 0.1},
+			sig:  crossAt(indicator.GoldenCross, 100, 95, 110),
+			bars: []bar{{94, 100, 96}},
+			// Bought at 100.1, sold at 95 less slippage, fees on both fills
+			reason: "stop", exit: 94.905,
+			pnl: (94.905-100.1)*1000/100.1 - (100.1+94.905)*1000/100.1*0.001,
+		},
+		{
+			name: "no levels stay open",
+			sig:  crossAt(indicator.GoldenCross, 100, 0),
+			bars: []bar{{50, 200, 120}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTrader(tt.cfg)
+			drive(tr, tt.sig, tt.bars)
+
+			trades := tr.Trades()
+			if tt.reason == "" {
+				if len(trades) != 0 || len(tr.Positions()) != 1 {
+					t.Fatalf("got %d trades and %d positions, want one open position", len(trades), len(tr.Positions()))
+				}
+				return
+			}
+			if len(trades) != 1 {
+				t.Fatalf("got %d trades, want 1", len(trades))
+			}
+			trade := trades[0]
+			if trade.Reason != tt.reason || !near(trade.Exit, tt.exit) || !near(trade.PnL, tt.pnl) {
+				t.Errorf("trade = %s at %v with PnL %v, want %s at %v with PnL %v",
+					trade.Reason, trade.Exit, trade.PnL, tt.reason, tt.exit, tt.pnl)
+			}
+			if s := tr.Summary(time.Time{}); !near(s.Balance, 10000+tt.pnl) || len(s.Positions) != 0 {
+				t.Errorf("summary balance %v with %d positions, want %v and none", s.Balance, len(s.Positions), 10000+tt.pnl)
+			}
+		})
+	}
+}
+
+func TestTraderPartialExitKeepsRemainder(t *testing.T) {
+	tr := newTestTrader(config.PaperConfig{})
+	drive(tr, crossAt(indicator.GoldenCross, 100, 95, 105, 110), []bar{{100, 106, 106}})
+
+	positions := tr.Positions()
+	if len(positions) != 1 {
+		t.Fatalf("got %d positions, want 1", len(positions))
+	}
+	p := positions[0]
+	if !near(p.Quantity, 5) || !near(p.Realized, 25) || !near(p.Unrealized, 30) || len(p.TakeProfits) != 1 {
+		t.Errorf("position = %+v, want 5 left, 25 realized, 30 unrealized and one target", p)
+	}
+	if s := tr.Summary(time.Time{}); !near(s.Equity, 10055) {
+		t.Errorf("equity = %v, want 10055", s.Equity)
+	}
+}
+
+func TestTraderSizing(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.PaperConfig
+		stop     float64
+		quantity float64 // 0 when no position opens
+	}{
+		{"notional", config.PaperConfig{Sizing: "notional", Size: 1000}, 95, 10},
+		{"equity_pct", config.PaperConfig{Sizing: "equity_pct", Size: 20}, 95, 20},
+		// 1% of 10000 lost over a 5 point stop
+		{"risk_pct", config.PaperConfig{Sizing: "risk_pct", Size: 1}, 95, 20},
+		{"risk_pct capped by leverage", config.PaperConfig{Sizing: "risk_pct", Size: 1, MaxLeverage: 0.1}, 95, 10},
+		{"risk_pct without a stop", config.PaperConfig{Sizing: "risk_pct", Size: 1}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTrader(tt.cfg)
+			drive(tr, crossAt(indicator.GoldenCross, 100, tt.stop), nil)
+
+			positions := tr.Positions()
+			if tt.quantity == 0 {
+				if len(positions) != 0 {
+					t.Fatalf("opened %+v, want no position", positions[0])
+				}
+				return
+			}
+			if len(positions) != 1 || !near(positions[0].Quantity, tt.quantity) {
+				t.Fatalf("positions = %+v, want quantity %v", positions, tt.quantity)
+			}
+		})
+	}
+}
+
+func TestTraderExitsOnOpposite(t *testing.T) {
+	tr := newTestTrader(config.PaperConfig{ExitOnOpposite: true})
+	drive(tr, crossAt(indicator.GoldenCross, 100, 0), nil)
+
+	death := crossAt(indicator.DeathCross, 90, 0)
+	death.EventTime = start.Add(time.Hour)
+	drive(tr, death, nil)
+
+	trades := tr.Trades()
+	if len(trades) != 1 || trades[0].Reason != "opposite" || !near(trades[0].PnL, -100) {
+		t.Fatalf("trades = %+v, want the long closed at 90 for -100", trades)
+	}
+	if positions := tr.Positions(); len(positions) != 1 || positions[0].Side != "short" {
+		t.Errorf("positions = %+v, want a short", positions)
+	}
+}
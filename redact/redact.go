@@ -0,0 +1,127 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces secret values.
+const Placeholder = "[REDACTED]"
+
+// minLength keeps very short values from masking unrelated text.
+const minLength = 4
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+)
+
+// Add registers secret values, e.g. webhook URLs and signing secrets, so they
+// are masked wherever String, Error or Bytes are applied. Their JSON-escaped
+// forms are registered too, so Bytes also masks them in encoded JSON. Values
+// stay registered for the life of the process.
+func Add(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range values {
+		for _, form := range []string{v, jsonEscaped(v)} {
+			if len(form) < minLength || containsString(secrets, form) {
+				continue
+			}
+			secrets = append(secrets, form)
+		}
+	}
+	// Longest first so a secret containing another is masked whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// String masks every registered secret in s.
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, secret := range secrets {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Placeholder)
+		}
+	}
+	return s
+}
+
+// Bytes masks every registered secret in b. b is returned unchanged when it
+// contains none.
+func Bytes(b []byte) []byte {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, secret := range secrets {
+		if bytes.Contains(b, []byte(secret)) {
+			b = bytes.ReplaceAll(b, []byte(secret), []byte(Placeholder))
+		}
+	}
+	return b
+}
+
+// Error returns err with registered secrets masked in its message.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := String(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return redactedError{msg: msg, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e redactedError) Error() string { return e.msg }
+func (e redactedError) Unwrap() error { return e.err }
+
+// URL masks the password and query values of a URL in addition to
+// registered secrets.
+func URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return String(raw)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Placeholder)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			q.Set(key, Placeholder)
+		}
+		u.RawQuery = q.Encode()
+	}
+	return String(u.String())
+}
+
+// jsonEscaped returns v as it appears inside a JSON string.
+func jsonEscaped(v string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return v
+	}
+	// Drop the quotes and the trailing newline
+	quoted := strings.TrimSuffix(buf.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package risk
+
+import (
+	"math"
+	"sort"
+
+	"fibo-monitor/config"
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+	"fibo-monitor/signal"
+
+	"go.uber.org/zap"
+)
+
+// Fibonacci ratios of the swing range used as stop and target levels.
+// Ratios above 1 are extensions beyond the swing.
+var fibRatios = []float64{0, 0.236, 0.382, 0.5, 0.618, 0.786, 1, 1.272, 1.618, 2.618}
+
+// Planner attaches suggested stop-loss and take-profit levels to signals,
+// based on the candle history of the signal's pair.
+type Planner struct {
+	config config.RiskConfig
+	store  *kline.Store
+	logger *zap.Logger
+}
+
+func NewPlanner(cfg config.RiskConfig, store *kline.Store, logger *zap.Logger) *Planner {
+	return &Planner{
+		config: cfg,
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (p *Planner) Run(inChan <-chan signal.Signal) <-chan signal.Signal {
+	outChan := make(chan signal.Signal, 100)
+
+	go func() {
+		defer close(outChan)
+		for sig := range inChan {
+			if plan, ok := p.Plan(sig); ok {
+				sig.Risk = &plan
+			}
+			outChan <- sig
+		}
+	}()
+
+	return outChan
+}
+
+// Plan computes the risk levels for a signal. It returns false when there
+// is not enough history yet.
+func (p *Planner) Plan(sig signal.Signal) (signal.RiskPlan, bool) {
+	rule := p.config.ForInterval(sig.Interval)
+	candles := p.store.Snapshot(sig.Symbol, sig.Interval)
+
+	atr := indicator.NewATR(rule.ATRPeriod)
+	for _, c := range candles {
+		atr.UpdateAndCommit(c.High, c.Low, c.Close)
+	}
+	if !atr.Ready() {
+		p.logger.Debug("Not enough history for risk plan",
+			zap.String("symbol", sig.Symbol),
+			zap.String("interval", sig.Interval),
+			zap.Int("candles", len(candles)),
+		)
+		return signal.RiskPlan{}, false
+	}
+
+	// direction is +1 for longs (golden cross) and -1 for shorts
+	direction := 1.0
+	if sig.Type == indicator.DeathCross {
+		direction = -1
+	}
+
+	plan := signal.RiskPlan{Method: "atr", ATR: atr.Value()}
+	if rule.Method == "fibonacci" {
+		lookback := rule.FibLookback
+		if lookback > len(candles) {
+			lookback = len(candles)
+		}
+		if stop, targets, ok := fibonacciLevels(candles[len(candles)-lookback:], sig.Price, direction, len(rule.TakeProfitATR)); ok {
+			plan.Method = "fibonacci"
+			plan.StopLoss = stop
+			plan.TakeProfits = targets
+		}
+	}
+	if plan.Method == "atr" {
+		plan.StopLoss = sig.Price - direction*rule.StopLossATR*plan.ATR
+		for _, m := range rule.TakeProfitATR {
+			plan.TakeProfits = append(plan.TakeProfits, sig.Price+direction*m*plan.ATR)
+		}
+	}
+
+	if risk := math.Abs(sig.Price - plan.StopLoss); risk > 0 && len(plan.TakeProfits) > 0 {
+		plan.RiskReward = math.Abs(plan.TakeProfits[0]-sig.Price) / risk
+	}
+	return plan, true
+}
+
+// fibonacciLevels picks the nearest Fibonacci level beyond the entry as the stop
+// and the next levels in the trade direction as targets.
+func fibonacciLevels(candles []kline.Candle, price, direction float64, targets int) (float64, []float64, bool) {
+	if len(candles) == 0 {
+		return 0, nil, false
+	}
+	high, low := candles[0].High, candles[0].Low
+	for _, c := range candles[1:] {
+		high = math.Max(high, c.High)
+		low = math.Min(low, c.Low)
+	}
+	swing := high - low
+	if swing <= 0 {
+		return 0, nil, false
+	}
+
+	// Longs measure from the swing low upwards, shorts from the swing high downwards
+	levels := make([]float64, 0, len(fibRatios))
+	for _, r := range fibRatios {
+		if direction > 0 {
+			levels = append(levels, low+r*swing)
+		} else {
+			levels = append(levels, high-r*swing)
+		}
+	}
+	sort.Float64s(levels)
+
+	var stop float64
+	var above, below []float64
+	for _, l := range levels {
+		if l < price {
+			below = append(below, l)
+		} else if l > price {
+			above = append(above, l)
+		}
+	}
+
+	var profits []float64
+	if direction > 0 {
+		if len(below) == 0 {
+			return 0, nil, false
+		}
+		stop = below[len(below)-1]
+		profits = above
+	} else {
+		if len(above) == 0 {
+			return 0, nil, false
+		}
+		stop = above[0]
+		for i := len(below) - 1; i >= 0; i-- {
+			profits = append(profits, below[i])
+		}
+	}
+
+	if len(profits) == 0 {
+		return 0, nil, false
+	}
+	if targets > 0 && len(profits) > targets {
+		profits = profits[:targets]
+	}
+	return stop, profits, true
+}
@@ -0,0 +1,139 @@
+package signal
+
+import (
+	"sort"
+	"time"
+
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+
+	"go.uber.org/zap"
+)
+
+// Trend is the direction of the short EMA relative to the long EMA.
+type Trend int
+
+const (
+	TrendDown Trend = -1
+	TrendNone Trend = 0
+	TrendUp   Trend = 1
+)
+
+func (t Trend) String() string {
+	switch t {
+	case TrendUp:
+		return "UP"
+	case TrendDown:
+		return "DOWN"
+	}
+	return "NONE"
+}
+
+// TimeframeTrend is the trend of one interval at the time of a signal.
+type TimeframeTrend struct {
+	Interval string
+	Trend    Trend
+}
+
+// TrendSource reports the committed trend of a symbol/interval pair.
+type TrendSource interface {
+	Trend(symbol, interval string) (Trend, bool)
+}
+
+// Confluence checks signals against the trend of the same symbol on higher intervals.
+type Confluence struct {
+	intervals  []string // candidate intervals, any order
+	filter     bool     // drop signals against a higher-timeframe trend
+	minAligned int      // timeframes (including the signal's own) needed to upgrade
+	trends     TrendSource
+	logger     *zap.Logger
+}
+
+func NewConfluence(intervals []string, filter bool, minAligned int, trends TrendSource, logger *zap.Logger) *Confluence {
+	return &Confluence{
+		intervals:  intervals,
+		filter:     filter,
+		minAligned: minAligned,
+		trends:     trends,
+		logger:     logger,
+	}
+}
+
+func (c *Confluence) Run(inChan <-chan Signal) <-chan Signal {
+	outChan := make(chan Signal, 100)
+
+	go func() {
+		defer close(outChan)
+		for sig := range inChan {
+			if c.evaluate(&sig) {
+				outChan <- sig
+			}
+		}
+	}()
+
+	return outChan
+}
+
+// evaluate annotates the signal and reports whether it should be kept.
+func (c *Confluence) evaluate(sig *Signal) bool {
+	want := TrendUp
+	if sig.Type == indicator.DeathCross {
+		want = TrendDown
+	}
+
+	sig.Timeframes = nil
+	aligned := 1 // the signal's own timeframe
+	for _, interval := range c.higherIntervals(sig.Interval) {
+		trend, ok := c.trends.Trend(sig.Symbol, interval)
+		if !ok {
+			continue
+		}
+		sig.Timeframes = append(sig.Timeframes, TimeframeTrend{Interval: interval, Trend: trend})
+
+		switch trend {
+		case want:
+			aligned++
+		case -want:
+			if c.filter {
+				c.logger.Info("Signal against higher timeframe trend dropped",
+					zap.String("symbol", sig.Symbol),
+					zap.String("interval", sig.Interval),
+					zap.String("type", sig.String()),
+					zap.String("higher_interval", interval),
+				)
+				return false
+			}
+		}
+	}
+
+	sig.Confluence = c.minAligned > 1 && aligned >= c.minAligned
+	return true
+}
+
+// higherIntervals returns the configured intervals longer than the given one,
+// longest first. Intervals without a fixed duration (e.g. tick bars) have none.
+func (c *Confluence) higherIntervals(interval string) []string {
+	size, err := kline.ParseInterval(interval)
+	if err != nil {
+		return nil
+	}
+
+	type sized struct {
+		name string
+		size time.Duration
+	}
+	var higher []sized
+	for _, i := range c.intervals {
+		s, err := kline.ParseInterval(i)
+		if err == nil && s > size {
+			higher = append(higher, sized{i, s})
+		}
+	}
+	sort.Slice(higher, func(a, b int) bool { return higher[a].size > higher[b].size })
+
+	out := make([]string, 0, len(higher))
+	for _, h := range higher {
+		out = append(out, h.name)
+	}
+	return out
+}
@@ -1,9 +1,11 @@
 package signal
 
 import (
+	"math"
 	"sync"
 	"time"
 
+	"fibo-monitor/clock"
 	"fibo-monitor/data/kline"
 	"fibo-monitor/indicator"
 
@@ -11,100 +13,334 @@ import (
 )
 
 type Signal struct {
-	Type       indicator.CrossType
-	Symbol     string
-	Interval   string
-	Price      float64
-	ShortEMA   float64
-	LongEMA    float64
-	Timestamp  time.Time
+	Type     indicator.CrossType
+	Symbol   string
+	Interval string
+	Price    float64
+	ShortEMA float64
+	LongEMA  float64
+	// EMAs as of the previous closed candle
+	PrevShortEMA float64
+	PrevLongEMA  float64
+	// Volume of the candle the signal fired on, so far
+	Volume float64
+	// Timestamp is when the signal was detected by the pipeline clock
+	Timestamp time.Time
+	// EventTime is the exchange event time of the kline update
+	EventTime time.Time
+	// CandleStart and CandleClose bound the candle the signal fired on
+	CandleStart time.Time
+	CandleClose time.Time
+	// Risk is attached by the risk planner when enabled
+	Risk *RiskPlan
+	// Timeframes holds the trend of the higher intervals consulted by the
+	// confluence stage; Confluence is set when enough of them align.
+	Timeframes []TimeframeTrend
+	Confluence bool
+	// Score and Severity are set by the scorer
+	Score    float64
+	Severity Severity
+}
+
+// RiskPlan holds suggested exit levels for a signal.
+type RiskPlan struct {
+	Method      string // atr or fibonacci
+	ATR         float64
+	StopLoss    float64
+	TakeProfits []float64
+	RiskReward  float64 // reward to the first target divided by risk to the stop
 }
 
 type Detector struct {
-	shortPeriod int
-	longPeriod  int
-	// state: symbol -> interval -> *state
+	strategies StrategySource
+	arithmetic indicator.Arithmetic
+	hysteresis Hysteresis
+	// state: symbol -> interval -> *pairState
 	state  map[string]map[string]*pairState
 	mu     sync.Mutex
+	clock  clock.Clock
 	logger *zap.Logger
+
+	// suppressed keeps the most recent whipsaw crosses for analysis
+	suppressed []SuppressedCross
 }
 
 type pairState struct {
-	ShortEMA *indicator.EMA
-	LongEMA  *indicator.EMA
+	// EMA periods the tracker was built with
+	shortPeriod int
+	longPeriod  int
+	tracker     crossTracker
+	atr         *indicator.ATR
+	// pending is a raw cross waiting for hysteresis confirmation
+	pending *pendingCross
+	// confirmed is the last confirmed cross; with hysteresis configured the
+	// same cross is not reported again until the EMAs separate the other way
+	confirmed indicator.CrossType
+}
+
+type pendingCross struct {
+	Type      indicator.CrossType
+	Detected  time.Time
+	Ticks     int
+	Bars      int
+	MaxSpread float64 // widest |short - long| seen, % of price
+}
+
+// Hysteresis decides when a raw crossover counts. With the zero value every
+// raw crossover counts immediately.
+type Hysteresis struct {
+	MinSpreadPct float64 // |short - long| as % of price must reach this
+	MinSpreadATR float64 // |short - long| as a fraction of ATR must reach this
+	ATRPeriod    int
+	ConfirmTicks int // ticks the cross must hold, including the first
+	ConfirmBars  int // closed bars the cross must hold
+	RecordSize   int // suppressed crosses kept for analysis
+}
+
+// enabled reports whether any rule can hold back a raw crossover.
+func (h Hysteresis) enabled() bool {
+	return h.MinSpreadPct > 0 || h.MinSpreadATR > 0 || h.ConfirmTicks > 1 || h.ConfirmBars > 0
+}
+
+// SuppressedCross is a raw crossover that reverted before it was confirmed.
+type SuppressedCross struct {
+	Type         string    `json:"type"`
+	Symbol       string    `json:"symbol"`
+	Interval     string    `json:"interval"`
+	Detected     time.Time `json:"detected"`
+	Suppressed   time.Time `json:"suppressed"`
+	Ticks        int       `json:"ticks"`
+	Bars         int       `json:"bars"`
+	MaxSpreadPct float64   `json:"max_spread_pct"`
 }
 
-func NewDetector(shortPeriod, longPeriod int, logger *zap.Logger) *Detector {
+func NewDetector(strategies StrategySource, arithmetic indicator.Arithmetic, hysteresis Hysteresis, clk clock.Clock, logger *zap.Logger) *Detector {
+	if hysteresis.ATRPeriod <= 0 {
+		hysteresis.ATRPeriod = 14
+	}
 	return &Detector{
-		shortPeriod: shortPeriod,
-		longPeriod:  longPeriod,
-		state:       make(map[string]map[string]*pairState),
-		logger:      logger,
+		strategies: strategies,
+		arithmetic: arithmetic,
+		hysteresis: hysteresis,
+		state:      make(map[string]map[string]*pairState),
+		clock:      clk,
+		logger:     logger,
 	}
 }
 
+// Detect emits the crossovers in inChan. A clock.Advancer is advanced to each
+// event's time before it is processed, so signal timestamps do not depend on
+// how far upstream stages have run ahead.
 func (d *Detector) Detect(inChan <-chan kline.KlineEvent) <-chan Signal {
 	outChan := make(chan Signal, 100)
 
 	go func() {
 		defer close(outChan)
 		for event := range inChan {
+			if a, ok := d.clock.(clock.Advancer); ok {
+				a.Advance(time.UnixMilli(event.Time))
+			}
 			d.mu.Lock()
 			// Initialize map for symbol if not exists
 			if _, ok := d.state[event.Symbol]; !ok {
 				d.state[event.Symbol] = make(map[string]*pairState)
 			}
-			
-			// Initialize state for interval if not exists
-			if _, ok := d.state[event.Symbol][event.Kline.Interval]; !ok {
-				d.state[event.Symbol][event.Kline.Interval] = &pairState{
-					ShortEMA: indicator.NewEMA(d.shortPeriod),
-					LongEMA:  indicator.NewEMA(d.longPeriod),
+
+			// Initialize state for interval if not exists, or start over
+			// when the pair's EMA periods have been changed
+			strategy := d.strategies.Strategy(event.Symbol, event.Kline.Interval)
+			state, ok := d.state[event.Symbol][event.Kline.Interval]
+			if !ok || state.shortPeriod != strategy.EmaShortPeriod || state.longPeriod != strategy.EmaLongPeriod {
+				if ok {
+					d.logger.Info("EMA periods changed, resetting pair",
+						zap.String("symbol", event.Symbol),
+						zap.String("interval", event.Kline.Interval),
+						zap.Int("short", strategy.EmaShortPeriod),
+						zap.Int("long", strategy.EmaLongPeriod),
+					)
 				}
+				state = &pairState{
+					shortPeriod: strategy.EmaShortPeriod,
+					longPeriod:  strategy.EmaLongPeriod,
+					tracker:     newCrossTracker(d.arithmetic, strategy.EmaShortPeriod, strategy.EmaLongPeriod),
+					atr:         indicator.NewATR(d.hysteresis.ATRPeriod),
+				}
+				d.state[event.Symbol][event.Kline.Interval] = state
 			}
-
-			state := d.state[event.Symbol][event.Kline.Interval]
 			d.mu.Unlock()
 
-			price, err := event.Kline.GetClosePrice()
-			if err != nil {
-				d.logger.Error("Invalid price", zap.Error(err))
-				continue
-			}
+			// Check crossover of the current tick against the committed EMAs
+			crossType, currShort, currLong := state.tracker.Preview(event)
 
-			// Calculate current EMAs (temporary for this tick)
-			// The stored EMA values are from the *previous closed* candle (or initial).
-			// So 'prev' corresponds to the state at the beginning of this candle.
-			// 'curr' corresponds to the state right now.
-			
-			prevShort := state.ShortEMA.Value
-			prevLong := state.LongEMA.Value
-			
-			currShort := state.ShortEMA.Calculate(price)
-			currLong := state.LongEMA.Calculate(price)
-
-			// Check crossover
-			crossType := indicator.CheckCrossover(prevShort, prevLong, currShort, currLong, price)
-
-			if crossType != indicator.None {
+			if confirmed := d.confirm(state, event, crossType, currShort, currLong); confirmed != indicator.None {
+				prevShort, prevLong := state.tracker.Committed()
 				outChan <- Signal{
-					Type:      crossType,
-					Symbol:    event.Symbol,
-					Interval:  event.Kline.Interval,
-					Price:     price,
-					ShortEMA:  currShort,
-					LongEMA:   currLong,
-					Timestamp: time.Now(),
+					Type:         confirmed,
+					Symbol:       event.Symbol,
+					Interval:     event.Kline.Interval,
+					Price:        event.Candle.Close,
+					ShortEMA:     currShort,
+					LongEMA:      currLong,
+					PrevShortEMA: prevShort,
+					PrevLongEMA:  prevLong,
+					Volume:       event.Candle.Volume,
+					Timestamp:    d.clock.Now(),
+					EventTime:    time.UnixMilli(event.Time).UTC(),
+					CandleStart:  event.Candle.StartTime,
+					CandleClose:  event.Candle.CloseTime,
 				}
 			}
 
 			// If candle is closed, update the settled EMA state
 			if event.Kline.IsClosed {
-				state.ShortEMA.UpdateAndCommit(price)
-				state.LongEMA.UpdateAndCommit(price)
+				d.mu.Lock()
+				state.tracker.Commit(event)
+				d.mu.Unlock()
+				state.atr.UpdateAndCommit(event.Candle.High, event.Candle.Low, event.Candle.Close)
 			}
 		}
 	}()
 
 	return outChan
 }
+
+// confirm tracks raw crosses through the hysteresis rules and returns the
+// cross type once one is confirmed.
+func (d *Detector) confirm(state *pairState, event kline.KlineEvent, raw indicator.CrossType, short, long float64) indicator.CrossType {
+	price := event.Candle.Close
+	spread := short - long
+
+	if state.confirmed == indicator.GoldenCross && spread < 0 || state.confirmed == indicator.DeathCross && spread > 0 {
+		state.confirmed = indicator.None
+	}
+
+	if p := state.pending; p != nil && !crossHolds(p.Type, spread, price, long) {
+		d.suppress(event, p)
+		state.pending = nil
+	}
+
+	if state.pending == nil {
+		if raw == indicator.None || raw == state.confirmed {
+			return indicator.None
+		}
+		state.pending = &pendingCross{Type: raw, Detected: d.clock.Now()}
+	}
+
+	p := state.pending
+	p.Ticks++
+	if event.Kline.IsClosed {
+		p.Bars++
+	}
+	spreadPct := math.Abs(spread) / price * 100
+	p.MaxSpread = math.Max(p.MaxSpread, spreadPct)
+
+	h := d.hysteresis
+	if p.Ticks < h.ConfirmTicks || p.Bars < h.ConfirmBars {
+		return indicator.None
+	}
+	if spreadPct < h.MinSpreadPct {
+		return indicator.None
+	}
+	if h.MinSpreadATR > 0 && (!state.atr.Ready() || math.Abs(spread) < h.MinSpreadATR*state.atr.Value()) {
+		return indicator.None
+	}
+
+	state.pending = nil
+	if h.enabled() {
+		state.confirmed = p.Type
+	}
+	return p.Type
+}
+
+// crossHolds reports whether the EMAs and price are still on the crossed side.
+func crossHolds(cross indicator.CrossType, spread, price, long float64) bool {
+	if cross == indicator.GoldenCross {
+		return spread > 0 && price > long
+	}
+	return spread < 0 && price < long
+}
+
+func (d *Detector) suppress(event kline.KlineEvent, p *pendingCross) {
+	record := SuppressedCross{
+		Type:         Signal{Type: p.Type}.String(),
+		Symbol:       event.Symbol,
+		Interval:     event.Kline.Interval,
+		Detected:     p.Detected,
+		Suppressed:   d.clock.Now(),
+		Ticks:        p.Ticks,
+		Bars:         p.Bars,
+		MaxSpreadPct: p.MaxSpread,
+	}
+	d.logger.Info("Whipsaw cross suppressed",
+		zap.String("symbol", record.Symbol),
+		zap.String("interval", record.Interval),
+		zap.String("type", record.Type),
+		zap.Int("ticks", record.Ticks),
+		zap.Int("bars", record.Bars),
+		zap.Float64("max_spread_pct", record.MaxSpreadPct),
+	)
+
+	if d.hysteresis.RecordSize <= 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.suppressed = append(d.suppressed, record)
+	if over := len(d.suppressed) - d.hysteresis.RecordSize; over > 0 {
+		d.suppressed = append(d.suppressed[:0], d.suppressed[over:]...)
+	}
+}
+
+// Suppressed returns the recorded whipsaw crosses, oldest first.
+func (d *Detector) Suppressed() []SuppressedCross {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]SuppressedCross(nil), d.suppressed...)
+}
+
+// Warm rebuilds the state of a pair from closed candles, oldest first, e.g.
+// candle history restored after a restart.
+func (d *Detector) Warm(symbol, interval string, candles []kline.Candle) {
+	strategy := d.strategies.Strategy(symbol, interval)
+	state := &pairState{
+		shortPeriod: strategy.EmaShortPeriod,
+		longPeriod:  strategy.EmaLongPeriod,
+		tracker:     newCrossTracker(d.arithmetic, strategy.EmaShortPeriod, strategy.EmaLongPeriod),
+		atr:         indicator.NewATR(d.hysteresis.ATRPeriod),
+	}
+	for _, c := range candles {
+		state.tracker.Commit(kline.KlineEvent{Symbol: symbol, Candle: c})
+		state.atr.UpdateAndCommit(c.High, c.Low, c.Close)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.state[symbol]; !ok {
+		d.state[symbol] = make(map[string]*pairState)
+	}
+	d.state[symbol][interval] = state
+}
+
+// Drop forgets the state of a pair that is no longer monitored.
+func (d *Detector) Drop(symbol, interval string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.state[symbol], interval)
+	if len(d.state[symbol]) == 0 {
+		delete(d.state, symbol)
+	}
+}
+
+// Trend returns the committed EMA relationship of a pair, i.e. the trend as of
+// the last closed candle. It returns false if the pair has not been seen.
+func (d *Detector) Trend(symbol, interval string) (Trend, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.state[symbol][interval]
+	if !ok {
+		return TrendNone, false
+	}
+	return state.tracker.Trend(), true
+}
@@ -2,30 +2,80 @@ package signal
 
 import (
 	"fmt"
+	"math"
+	"strings"
 	"sync"
 	"time"
 
+	"fibo-monitor/clock"
+	"fibo-monitor/data/kline"
 	"fibo-monitor/indicator"
 
 	"go.uber.org/zap"
 )
 
 type Filter struct {
-	dedupWindow time.Duration
-	// cache: key -> timestamp
-	lastSignalTime map[string]time.Time
-	mu             sync.Mutex
-	logger         *zap.Logger
+	strategies StrategySource
+	policy     DedupPolicy
+	// cache: key -> last alert
+	lastSignal map[string]lastAlert
+	lastEvict  time.Time
+	mu         sync.Mutex
+	clock      clock.Clock
+	logger     *zap.Logger
 }
 
-func NewFilter(dedupWindow time.Duration, logger *zap.Logger) *Filter {
+// DedupPolicy refines how repeated signals are suppressed.
+type DedupPolicy struct {
+	// PerPair deduplicates per symbol/interval regardless of direction,
+	// so a death cross right after a golden cross is suppressed too.
+	PerPair bool
+	// MinPriceMovePct lets a signal through inside the window if price has
+	// moved at least this much since the last alert.
+	MinPriceMovePct float64
+	// EvictionInterval is how often expired entries are removed. Eviction is
+	// lazy: it runs when a signal arrives at least this long, in event time,
+	// after the last one, so a quiet filter keeps its entries. That is at
+	// most one per pair and cross type, and expired entries never suppress.
+	EvictionInterval time.Duration
+}
+
+type lastAlert struct {
+	price   float64
+	expires time.Time
+}
+
+// NewFilter creates a filter deduplicating signals within each pair's dedup
+// window and dropping signals below the pair's minimum volume.
+func NewFilter(strategies StrategySource, policy DedupPolicy, clk clock.Clock, logger *zap.Logger) *Filter {
+	if policy.EvictionInterval <= 0 {
+		policy.EvictionInterval = time.Hour
+	}
 	return &Filter{
-		dedupWindow:    dedupWindow,
-		lastSignalTime: make(map[string]time.Time),
-		logger:         logger,
+		strategies: strategies,
+		policy:     policy,
+		lastSignal: make(map[string]lastAlert),
+		lastEvict:  clk.Now(),
+		clock:      clk,
+		logger:     logger,
 	}
 }
 
+// SetPolicy replaces the dedup settings. Alerts already recorded keep their
+// expiry, unless PerPair changes: their keys no longer match and they are
+// forgotten.
+func (f *Filter) SetPolicy(policy DedupPolicy) {
+	if policy.EvictionInterval <= 0 {
+		policy.EvictionInterval = time.Hour
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if policy.PerPair != f.policy.PerPair {
+		f.lastSignal = make(map[string]lastAlert)
+	}
+	f.policy = policy
+}
+
 func (f *Filter) Run(inChan <-chan Signal) <-chan Signal {
 	outChan := make(chan Signal, 100)
 
@@ -42,26 +92,127 @@ func (f *Filter) Run(inChan <-chan Signal) <-chan Signal {
 }
 
 func (f *Filter) shouldProcess(sig Signal) bool {
+	strategy := f.strategies.Strategy(sig.Symbol, sig.Interval)
+	if sig.Volume < strategy.MinVolume {
+		f.logger.Debug("Signal below minimum volume",
+			zap.String("symbol", sig.Symbol),
+			zap.String("interval", sig.Interval),
+			zap.Float64("volume", sig.Volume),
+			zap.Float64("min_volume", strategy.MinVolume),
+		)
+		return false
+	}
+
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	now := sig.Time()
+	if now.Sub(f.lastEvict) >= f.policy.EvictionInterval {
+		f.evict(now)
+	}
+
 	key := fmt.Sprintf("%s-%s-%d", sig.Symbol, sig.Interval, sig.Type)
-	lastTime, ok := f.lastSignalTime[key]
+	if f.policy.PerPair {
+		key = fmt.Sprintf("%s-%s", sig.Symbol, sig.Interval)
+	}
+	last, ok := f.lastSignal[key]
 
-	if ok && time.Since(lastTime) < f.dedupWindow {
-		// Duplicate signal
-		return false
+	if ok && now.Before(last.expires) {
+		moved := last.price > 0 && math.Abs(sig.Price-last.price)/last.price*100 >= f.policy.MinPriceMovePct
+		if f.policy.MinPriceMovePct <= 0 || !moved {
+			// Duplicate signal
+			return false
+		}
+	}
+
+	dedupWindow, err := window(strategy, sig.Interval)
+	if err != nil {
+		// Rejected by config validation; fall back rather than never dedup
+		f.logger.Error("Invalid dedup window, using deduplication_window",
+			zap.String("symbol", sig.Symbol),
+			zap.String("interval", sig.Interval),
+			zap.Error(err),
+		)
+	}
+
+	// Update last signal
+	f.lastSignal[key] = lastAlert{
+		price:   sig.Price,
+		expires: now.Add(dedupWindow),
 	}
 
-	// Update last signal time
-	f.lastSignalTime[key] = time.Now()
-	
-	// Also, if we want to ensure we don't spam, maybe we should check if the *opposite* signal happened recently? 
-	// But the simple deduplication window per signal type is what's requested.
-	
 	return true
 }
 
+// Drop forgets the alerts recorded for a pair no longer monitored.
+func (f *Filter) Drop(symbol, interval string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pair := fmt.Sprintf("%s-%s", symbol, interval)
+	for key := range f.lastSignal {
+		if key == pair || strings.HasPrefix(key, pair+"-") {
+			delete(f.lastSignal, key)
+		}
+	}
+}
+
+// DedupEntry is a recorded alert that suppresses repeats until it expires.
+type DedupEntry struct {
+	Key     string    `json:"key"`
+	Price   float64   `json:"price"`
+	Expires time.Time `json:"expires"`
+}
+
+// Entries returns the alerts currently suppressing repeats.
+func (f *Filter) Entries() []DedupEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.clock.Now()
+	var entries []DedupEntry
+	for key, last := range f.lastSignal {
+		if now.Before(last.expires) {
+			entries = append(entries, DedupEntry{Key: key, Price: last.price, Expires: last.expires})
+		}
+	}
+	return entries
+}
+
+// Restore records previously dumped alerts, e.g. after a restart.
+func (f *Filter) Restore(entries []DedupEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, e := range entries {
+		f.lastSignal[e.Key] = lastAlert{price: e.Price, expires: e.Expires}
+	}
+}
+
+// window returns the dedup window of a pair. Counting candles needs an
+// interval of fixed length; otherwise the error is returned along with the
+// time window.
+func window(strategy Strategy, interval string) (time.Duration, error) {
+	if strategy.WindowCandles <= 0 {
+		return strategy.DeduplicationWindow, nil
+	}
+	size, err := kline.CandleLength(interval)
+	if err != nil {
+		return strategy.DeduplicationWindow, fmt.Errorf("window_candles: %w", err)
+	}
+	return time.Duration(strategy.WindowCandles) * size, nil
+}
+
+// evict drops entries whose window has passed. Callers must hold f.mu.
+func (f *Filter) evict(now time.Time) {
+	for key, last := range f.lastSignal {
+		if !now.Before(last.expires) {
+			delete(f.lastSignal, key)
+		}
+	}
+	f.lastEvict = now
+}
+
 // String representation for Signal Type for the key
 func (s Signal) String() string {
 	if s.Type == indicator.GoldenCross {
@@ -69,3 +220,18 @@ func (s Signal) String() string {
 	}
 	return "DEATH"
 }
+
+// Time is when the signal happened: the exchange event time, or the
+// detection time for signals without one. Stages after the detector use it
+// instead of the clock, which may already be ahead in replays.
+func (s Signal) Time() time.Time {
+	if s.EventTime.IsZero() {
+		return s.Timestamp
+	}
+	return s.EventTime
+}
+
+// ID identifies a signal across notification and callback round trips.
+func (s Signal) ID() string {
+	return fmt.Sprintf("%s-%s-%s-%d", s.Symbol, s.Interval, s.String(), s.Timestamp.UnixMilli())
+}
@@ -0,0 +1,63 @@
+package signal
+
+import (
+	"testing"
+	"time"
+
+	"fibo-monitor/clock"
+	"fibo-monitor/indicator"
+
+	"go.uber.org/zap"
+)
+
+type fixedStrategy Strategy
+
+func (s fixedStrategy) Strategy(symbol, interval string) Strategy { return Strategy(s) }
+
+func TestWindowCandles(t *testing.T) {
+	strategy := Strategy{DeduplicationWindow: time.Hour, WindowCandles: 3}
+	tests := []struct {
+		interval string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{"4h", 12 * time.Hour, false},
+		{"t15s", 45 * time.Second, false},
+		{"t5m", 15 * time.Minute, false},
+		{"tick500", time.Hour, true},
+		{"dollar5000000", time.Hour, true},
+	}
+	for _, tt := range tests {
+		got, err := window(strategy, tt.interval)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("window(%s) = %v, %v; want %v, error %v", tt.interval, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSetPolicyPerPairClearsAlerts(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFilter(fixedStrategy{DeduplicationWindow: time.Hour}, DedupPolicy{}, clock.NewSimulated(start), zap.NewNop())
+	golden := Signal{Type: indicator.GoldenCross, Symbol: "BTCUSDT", Interval: "1h", Price: 100, EventTime: start}
+	death := golden
+	death.Type = indicator.DeathCross
+
+	if !f.shouldProcess(golden) || f.shouldProcess(golden) {
+		t.Fatal("repeated golden cross not deduplicated")
+	}
+
+	// Per pair keys cannot see the per type alert, so it is forgotten
+	f.SetPolicy(DedupPolicy{PerPair: true})
+	if len(f.lastSignal) != 0 {
+		t.Fatalf("%d alerts kept after switching to per pair keys", len(f.lastSignal))
+	}
+	if !f.shouldProcess(death) || f.shouldProcess(golden) {
+		t.Fatal("per pair dedup not applied after the switch")
+	}
+
+	// Unrelated changes keep the alerts
+	f.SetPolicy(DedupPolicy{PerPair: true, MinPriceMovePct: 5})
+	if f.shouldProcess(golden) {
+		t.Error("alert lost on a policy change keeping per pair keys")
+	}
+}
@@ -0,0 +1,102 @@
+package signal
+
+import (
+	"sync"
+	"time"
+
+	"fibo-monitor/clock"
+
+	"go.uber.org/zap"
+)
+
+// MuteList silences symbol/interval pairs for a while, e.g. from a card button.
+type MuteList struct {
+	// muted: symbol-interval -> mute
+	muted  map[string]Mute
+	mu     sync.Mutex
+	clock  clock.Clock
+	logger *zap.Logger
+}
+
+// Mute is a pair silenced until a given time.
+type Mute struct {
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	Until    time.Time `json:"until"`
+}
+
+func NewMuteList(clk clock.Clock, logger *zap.Logger) *MuteList {
+	return &MuteList{
+		muted:  make(map[string]Mute),
+		clock:  clk,
+		logger: logger,
+	}
+}
+
+// Mute silences the pair until the given time.
+func (m *MuteList) Mute(symbol, interval string, until time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.muted[muteKey(symbol, interval)] = Mute{Symbol: symbol, Interval: interval, Until: until}
+}
+
+// Mutes returns the pairs muted at the given time.
+func (m *MuteList) Mutes(now time.Time) []Mute {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var mutes []Mute
+	for _, mute := range m.muted {
+		if now.Before(mute.Until) {
+			mutes = append(mutes, mute)
+		}
+	}
+	return mutes
+}
+
+// Unmute lifts a mute early.
+func (m *MuteList) Unmute(symbol, interval string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.muted, muteKey(symbol, interval))
+}
+
+// IsMuted reports whether the pair is muted at the given time.
+func (m *MuteList) IsMuted(symbol, interval string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := muteKey(symbol, interval)
+	mute, ok := m.muted[key]
+	if ok && !now.Before(mute.Until) {
+		delete(m.muted, key)
+		return false
+	}
+	return ok
+}
+
+// Run drops signals for muted pairs.
+func (m *MuteList) Run(inChan <-chan Signal) <-chan Signal {
+	outChan := make(chan Signal, 100)
+
+	go func() {
+		defer close(outChan)
+		for sig := range inChan {
+			if m.IsMuted(sig.Symbol, sig.Interval, sig.Time()) {
+				m.logger.Info("Signal muted",
+					zap.String("symbol", sig.Symbol),
+					zap.String("interval", sig.Interval),
+					zap.String("type", sig.String()),
+				)
+				continue
+			}
+			outChan <- sig
+		}
+	}()
+
+	return outChan
+}
+
+func muteKey(symbol, interval string) string {
+	return symbol + "-" + interval
+}
@@ -0,0 +1,220 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+
+	"fibo-monitor/config"
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+
+	"go.uber.org/zap"
+)
+
+// Severity ranks how strongly a signal should be brought to attention.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return "info"
+}
+
+// ParseSeverity parses "info", "warning" or "critical". An empty string is info.
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "", "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "critical":
+		return SeverityCritical, nil
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q", s)
+}
+
+// Factor saturation points: a factor reaches its full weight at these values.
+const (
+	slopeFullPct    = 0.05 // change of EMA spread since the last close, % of price
+	volumeFullRatio = 2.0  // candle volume relative to the recent average
+	distanceFullPct = 0.5  // distance of price from the long EMA, % of price
+)
+
+// Scorer rates signals from 0 to 100 and assigns a severity. Signals below
+// the minimum score are dropped. Each weight sets the contribution of a
+// factor; zero disables it, and with every weight zero all signals score 0.
+type Scorer struct {
+	config config.ScoringConfig
+	store  *kline.Store
+	logger *zap.Logger
+}
+
+func NewScorer(cfg config.ScoringConfig, store *kline.Store, logger *zap.Logger) *Scorer {
+	return &Scorer{
+		config: cfg,
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (s *Scorer) Run(inChan <-chan Signal) <-chan Signal {
+	outChan := make(chan Signal, 100)
+
+	go func() {
+		defer close(outChan)
+		for sig := range inChan {
+			sig.Score = s.Score(sig)
+			sig.Severity = s.severity(sig.Score)
+			if sig.Score < s.config.MinScore {
+				s.logger.Debug("Signal below minimum score",
+					zap.String("symbol", sig.Symbol),
+					zap.String("interval", sig.Interval),
+					zap.Float64("score", sig.Score),
+				)
+				continue
+			}
+			outChan <- sig
+		}
+	}()
+
+	return outChan
+}
+
+// Score returns the weighted average of the available factors, scaled to 0-100.
+// Factors that cannot be computed yet are left out of the average.
+func (s *Scorer) Score(sig Signal) float64 {
+	w := s.config.Weights
+	factors := []struct {
+		weight float64
+		value  func(Signal) (float64, bool)
+	}{
+		{w.Slope, s.slope},
+		{w.Volume, s.volume},
+		{w.Distance, s.distance},
+		{w.Alignment, s.alignment},
+		{w.RSI, s.rsi},
+	}
+
+	var total, weight float64
+	for _, f := range factors {
+		if f.weight <= 0 {
+			continue
+		}
+		if v, ok := f.value(sig); ok {
+			total += f.weight * clamp01(v)
+			weight += f.weight
+		}
+	}
+
+	if weight == 0 {
+		return 0
+	}
+	return 100 * total / weight
+}
+
+func (s *Scorer) severity(score float64) Severity {
+	switch {
+	case score >= s.config.CriticalScore:
+		return SeverityCritical
+	case score >= s.config.WarningScore:
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
+// slope measures how fast the EMAs are separating in the signal's direction.
+func (s *Scorer) slope(sig Signal) (float64, bool) {
+	if sig.Price <= 0 {
+		return 0, false
+	}
+	change := (sig.ShortEMA - sig.LongEMA) - (sig.PrevShortEMA - sig.PrevLongEMA)
+	if sig.Type == indicator.DeathCross {
+		change = -change
+	}
+	return change / sig.Price * 100 / slopeFullPct, true
+}
+
+// volume compares the candle's volume with the recent average, projecting
+// a forming candle's volume to its full duration.
+func (s *Scorer) volume(sig Signal) (float64, bool) {
+	history := s.store.Last(sig.Symbol, sig.Interval, s.config.VolumeLookback)
+	if len(history) == 0 {
+		return 0, false
+	}
+	var sum float64
+	for _, c := range history {
+		sum += c.Volume
+	}
+	avg := sum / float64(len(history))
+	if avg <= 0 {
+		return 0, false
+	}
+
+	volume := sig.Volume
+	if live, ok := s.store.Live(sig.Symbol, sig.Interval); ok {
+		length := live.CloseTime.Sub(live.StartTime)
+		elapsed := sig.EventTime.Sub(live.StartTime)
+		if length > 0 && elapsed > 0 {
+			fraction := math.Max(0.1, math.Min(1, float64(elapsed)/float64(length)))
+			volume /= fraction
+		}
+	}
+	return volume / avg / volumeFullRatio, true
+}
+
+// distance measures how far price has moved through the long EMA.
+func (s *Scorer) distance(sig Signal) (float64, bool) {
+	if sig.Price <= 0 {
+		return 0, false
+	}
+	return math.Abs(sig.Price-sig.LongEMA) / sig.Price * 100 / distanceFullPct, true
+}
+
+// alignment is the share of higher timeframes trending with the signal.
+func (s *Scorer) alignment(sig Signal) (float64, bool) {
+	if len(sig.Timeframes) == 0 {
+		return 0, false
+	}
+	want := TrendUp
+	if sig.Type == indicator.DeathCross {
+		want = TrendDown
+	}
+	aligned := 0
+	for _, tf := range sig.Timeframes {
+		if tf.Trend == want {
+			aligned++
+		}
+	}
+	return float64(aligned) / float64(len(sig.Timeframes)), true
+}
+
+// rsi rewards momentum in the signal's direction: RSI from 40 to 70 maps to
+// 0..1 for golden crosses, and from 60 down to 30 for death crosses.
+func (s *Scorer) rsi(sig Signal) (float64, bool) {
+	rsi := indicator.NewRSI(s.config.RSIPeriod)
+	for _, c := range s.store.Last(sig.Symbol, sig.Interval, s.config.RSIPeriod*4) {
+		rsi.UpdateAndCommit(c.Close)
+	}
+	if !rsi.Ready() {
+		return 0, false
+	}
+	value := rsi.Calculate(sig.Price)
+	if sig.Type == indicator.DeathCross {
+		return (60 - value) / 30, true
+	}
+	return (value - 40) / 30, true
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
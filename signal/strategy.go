@@ -0,0 +1,21 @@
+package signal
+
+import "time"
+
+// Strategy holds the settings in effect for one symbol/interval pair.
+type Strategy struct {
+	EmaShortPeriod      int
+	EmaLongPeriod       int
+	DeduplicationWindow time.Duration
+	// WindowCandles, when set, expresses the dedup window as a number of
+	// candles of the pair's interval instead of wall time.
+	WindowCandles int
+	// MinVolume drops signals whose candle volume so far is below it.
+	MinVolume float64
+}
+
+// StrategySource resolves the effective strategy of a pair. It is consulted
+// for every event, so changes take effect without a restart.
+type StrategySource interface {
+	Strategy(symbol, interval string) Strategy
+}
@@ -0,0 +1,113 @@
+package signal
+
+import (
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+
+	"github.com/shopspring/decimal"
+)
+
+// crossTracker holds the committed short/long EMAs of one pair.
+// Preview evaluates a tick against the committed values without changing them;
+// Commit folds a closed candle into the state.
+type crossTracker interface {
+	Preview(event kline.KlineEvent) (cross indicator.CrossType, shortEMA, longEMA float64)
+	Commit(event kline.KlineEvent)
+	// Committed returns the EMAs as of the last closed candle.
+	Committed() (shortEMA, longEMA float64)
+	// Trend is the relation of the committed short EMA to the long EMA.
+	Trend() Trend
+}
+
+func newCrossTracker(arithmetic indicator.Arithmetic, shortPeriod, longPeriod int) crossTracker {
+	if arithmetic == indicator.ArithmeticDecimal {
+		return &decimalTracker{
+			short: indicator.NewDecimalEMA(shortPeriod),
+			long:  indicator.NewDecimalEMA(longPeriod),
+		}
+	}
+	return &floatTracker{
+		short: indicator.NewEMA(shortPeriod),
+		long:  indicator.NewEMA(longPeriod),
+	}
+}
+
+type floatTracker struct {
+	short *indicator.EMA
+	long  *indicator.EMA
+}
+
+func (t *floatTracker) Preview(event kline.KlineEvent) (indicator.CrossType, float64, float64) {
+	price := event.Candle.Close
+
+	// The stored EMA values are from the *previous closed* candle (or initial).
+	// So 'prev' corresponds to the state at the beginning of this candle.
+	// 'curr' corresponds to the state right now.
+	prevShort := t.short.Value
+	prevLong := t.long.Value
+
+	currShort := t.short.Calculate(price)
+	currLong := t.long.Calculate(price)
+
+	return indicator.CheckCrossover(prevShort, prevLong, currShort, currLong, price), currShort, currLong
+}
+
+func (t *floatTracker) Commit(event kline.KlineEvent) {
+	t.short.UpdateAndCommit(event.Candle.Close)
+	t.long.UpdateAndCommit(event.Candle.Close)
+}
+
+func (t *floatTracker) Committed() (float64, float64) {
+	return t.short.Value, t.long.Value
+}
+
+func (t *floatTracker) Trend() Trend {
+	switch {
+	case t.short.Value > t.long.Value:
+		return TrendUp
+	case t.short.Value < t.long.Value:
+		return TrendDown
+	}
+	return TrendNone
+}
+
+type decimalTracker struct {
+	short *indicator.DecimalEMA
+	long  *indicator.DecimalEMA
+}
+
+func (t *decimalTracker) Preview(event kline.KlineEvent) (indicator.CrossType, float64, float64) {
+	price := decimalClose(event)
+
+	prevShort := t.short.Value
+	prevLong := t.long.Value
+
+	currShort := t.short.Calculate(price)
+	currLong := t.long.Calculate(price)
+
+	cross := indicator.CheckCrossoverDecimal(prevShort, prevLong, currShort, currLong, price)
+	return cross, currShort.InexactFloat64(), currLong.InexactFloat64()
+}
+
+func (t *decimalTracker) Commit(event kline.KlineEvent) {
+	price := decimalClose(event)
+	t.short.UpdateAndCommit(price)
+	t.long.UpdateAndCommit(price)
+}
+
+func (t *decimalTracker) Committed() (float64, float64) {
+	return t.short.Value.InexactFloat64(), t.long.Value.InexactFloat64()
+}
+
+func (t *decimalTracker) Trend() Trend {
+	return Trend(t.short.Value.Cmp(t.long.Value))
+}
+
+// decimalClose reads the close from the exchange string so no float rounding
+// enters the decimal path.
+func decimalClose(event kline.KlineEvent) decimal.Decimal {
+	if d, err := decimal.NewFromString(event.Kline.Close); err == nil {
+		return d
+	}
+	return decimal.NewFromFloat(event.Candle.Close)
+}
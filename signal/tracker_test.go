@@ -0,0 +1,75 @@
+package signal
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"testing"
+
+	"fibo-monitor/data/kline"
+	"fibo-monitor/indicator"
+)
+
+// The testdata files are synthetic, not recorded from the exchange: random
+// walks of 1m klines in Binance's CSV layout, with prices at exchange tick
+// precision. One is BTC-like (1 decimal), the other PEPE-like (10 decimals),
+// where float rounding is most likely to flip a comparison.
+func readCloses(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closes []string
+	for _, row := range rows[1:] {
+		closes = append(closes, row[1], row[4]) // open as a live tick, then the close
+	}
+	return closes
+}
+
+func TestFloatAndDecimalCrossesMatchOnSyntheticData(t *testing.T) {
+	for _, path := range []string{"testdata/synthetic_btcusdt_1m.csv", "testdata/synthetic_pepeusdt_1m.csv"} {
+		t.Run(path, func(t *testing.T) {
+			prices := readCloses(t, path)
+			float := newCrossTracker(indicator.ArithmeticFloat, 12, 144)
+			decimal := newCrossTracker(indicator.ArithmeticDecimal, 12, 144)
+
+			crosses := 0
+			for i, price := range prices {
+				f, err := strconv.ParseFloat(price, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				event := kline.KlineEvent{
+					Kline:  kline.Kline{Close: price, IsClosed: i%2 == 1},
+					Candle: kline.Candle{Close: f},
+				}
+
+				floatCross, _, _ := float.Preview(event)
+				decimalCross, _, _ := decimal.Preview(event)
+				if floatCross != decimalCross {
+					t.Fatalf("tick %d (%s): float %d, decimal %d", i, price, floatCross, decimalCross)
+				}
+				if floatCross != indicator.None {
+					crosses++
+				}
+				if event.Kline.IsClosed {
+					float.Commit(event)
+					decimal.Commit(event)
+					if float.Trend() != decimal.Trend() {
+						t.Fatalf("candle %d: float trend %d, decimal trend %d", i/2, float.Trend(), decimal.Trend())
+					}
+				}
+			}
+			if crosses == 0 {
+				t.Fatal("data has no crosses to compare")
+			}
+			t.Logf("%d matching crosses", crosses)
+		})
+	}
+}